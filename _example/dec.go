@@ -16,55 +16,39 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	f2, err := os.Open("./testdata/nal_1_1.xvc")
-	if err != nil {
-		panic(err)
-	}
-
-	data1, err := io.ReadAll(f1)
-	if err != nil {
-		panic(err)
-	}
+	defer f1.Close()
 
-	data2, err := io.ReadAll(f2)
+	f2, err := os.Open("./testdata/nal_1_1.xvc")
 	if err != nil {
 		panic(err)
 	}
+	defer f2.Close()
 
-	decoder, err := xvc.CreateDecoder(
+	decoder, err := xvc.NewStreamDecoder(io.MultiReader(f1, f2),
 		xvc.DecoderParameterMaxFramerate(30.0),
 	)
 	if err != nil {
 		panic(err)
 	}
+	defer decoder.Close()
 
-	if err := decoder.Decode(data1); err != nil {
-		panic(err)
-	}
-	if err := decoder.Decode(data2); err != nil {
-		panic(err)
-	}
-
-	if decoder.Flush() != true {
-		panic("failed to flush")
-	}
-
-	i := 0
-	for {
-		pic, err := decoder.DecodedPicture()
-		if err != nil {
+	for i := 0; ; i += 1 {
+		pic, err := decoder.NextFrame()
+		if err == io.EOF {
 			break
 		}
-		defer pic.Close()
+		if err != nil {
+			panic(err)
+		}
 
 		fmt.Printf("nals[%d] type=%s color_matrix=%s img=%T\n", i, pic.Type(), pic.ColorMatrix(), pic.Image())
 
 		path, err := saveImage(pic.Image())
+		pic.Close()
 		if err != nil {
 			panic(err)
 		}
 		fmt.Println("saved", path)
-		i += 1
 	}
 }
 
@@ -63,15 +63,16 @@ func main() {
 		copy(u, buf[s0:s0+s1])
 		copy(v, buf[s0+s1:s0+s1+s1])
 
-		since := time.Since(t)
-		nals, err := encoder.Encode(
-			y,            // y plane
-			u,            // u plane
-			v,            // v plane
-			width,        // y stride
-			width/2,      // u stride
-			width/2,      // v stride
-			int64(since), // int64 user_data
+		pts := encoder.Timebase().Ticks(time.Since(t))
+		nals, err := encoder.EncodeWithOptions(
+			y,             // y plane
+			u,             // u plane
+			v,             // v plane
+			width,         // y stride
+			width/2,       // u stride
+			width/2,       // v stride
+			int64(frames), // int64 user_data
+			xvc.EncodeOptions{PTS: pts},
 		)
 		if err != nil {
 			panic(err)
@@ -84,7 +85,7 @@ func main() {
 		}
 
 		for _, nal := range nals {
-			if err := decoder.Decode(nal.Bytes()); err != nil {
+			if err := decoder.DecodeNALUnit(nal); err != nil {
 				panic(err)
 			}
 		}
@@ -101,7 +102,7 @@ func main() {
 		}
 		defer pic.Close()
 
-		fmt.Printf("frame[%d] type=%s color_matrix=%d img=%T\n", frames, pic.Type(), pic.ColorMatrix(), pic.Image())
+		fmt.Printf("frame[%d] type=%s color_matrix=%d pts=%d user_data=%d img=%T\n", frames, pic.Type(), pic.ColorMatrix(), pic.PTS(), pic.UserData(), pic.Image())
 
 		path, err := saveImage(pic.Image())
 		if err != nil {
@@ -0,0 +1,231 @@
+package xvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+// AccessUnit is the NAL units of a picture,
+// non-picture NALs (SegmentHeader, Sei, AccessUnitDelimiter) preceding the picture NAL in decoding order.
+type AccessUnit struct {
+	NALs []*NALUnit
+}
+
+// Picture returns the picture NAL, nil if AccessUnit contains only non-picture NALs (e.g. trailing SegmentHeader)
+func (a *AccessUnit) Picture() *NALUnit {
+	for i := len(a.NALs) - 1; 0 <= i; i -= 1 {
+		if a.NALs[i].Type().IsPicture() {
+			return a.NALs[i]
+		}
+	}
+	return nil
+}
+
+// Type returns NALUnitType of the picture
+func (a *AccessUnit) Type() NALUnitType {
+	if pic := a.Picture(); pic != nil {
+		return pic.Type()
+	}
+	return NALUnitType(0xff)
+}
+
+// IsKeyframe returns whether the picture is IntraAccessPicture, decoding can be started from it.
+func (a *AccessUnit) IsKeyframe() bool {
+	return a.Type() == IntraAccessPicture
+}
+
+// HasSegmentHeader returns whether AccessUnit contains SegmentHeader
+func (a *AccessUnit) HasSegmentHeader() bool {
+	for _, nal := range a.NALs {
+		if nal.Type() == SegmentHeader {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AccessUnit) UserData() int64 {
+	if pic := a.Picture(); pic != nil {
+		return pic.UserData()
+	}
+	return 0
+}
+
+func (a *AccessUnit) PTS() int64 {
+	if pic := a.Picture(); pic != nil {
+		return pic.PTS()
+	}
+	return 0
+}
+
+func (a *AccessUnit) DTS() int64 {
+	if pic := a.Picture(); pic != nil {
+		return pic.DTS()
+	}
+	return 0
+}
+
+// Split returns length prefixed NAL units for Decoder.Decode
+func (a *AccessUnit) Split() [][]byte {
+	nals := make([][]byte, len(a.NALs))
+	for i, nal := range a.NALs {
+		nals[i] = nal.Bytes()
+	}
+	return nals
+}
+
+// Bytes returns concatenated length prefixed NAL units
+func (a *AccessUnit) Bytes() []byte {
+	size := 0
+	for _, nal := range a.NALs {
+		size += len(nal.Bytes())
+	}
+	buf := make([]byte, 0, size)
+	for _, nal := range a.NALs {
+		buf = append(buf, nal.Bytes()...)
+	}
+	return buf
+}
+
+// Decode decodes all NALs of AccessUnit
+func (a *AccessUnit) Decode(d *Decoder) error {
+	for _, nal := range a.NALs {
+		if err := d.DecodeNALUnit(nal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AccessUnit) Close() {
+	for _, nal := range a.NALs {
+		nal.Close()
+	}
+}
+
+// AccessUnitGrouper assembles NALs in decoding order into AccessUnits.
+// an AccessUnit is completed by its picture NAL.
+type AccessUnitGrouper struct {
+	pending []*NALUnit
+}
+
+func NewAccessUnitGrouper() *AccessUnitGrouper {
+	return &AccessUnitGrouper{
+		pending: make([]*NALUnit, 0, 4),
+	}
+}
+
+// Push appends nals and returns the completed AccessUnits
+func (g *AccessUnitGrouper) Push(nals ...*NALUnit) []*AccessUnit {
+	units := make([]*AccessUnit, 0, 1)
+	for _, nal := range nals {
+		g.pending = append(g.pending, nal)
+		if nal.Type().IsPicture() {
+			units = append(units, &AccessUnit{NALs: g.pending})
+			g.pending = make([]*NALUnit, 0, 4)
+		}
+	}
+	return units
+}
+
+// Flush returns pending non-picture NALs as AccessUnit, nil if there is nothing pending.
+func (g *AccessUnitGrouper) Flush() *AccessUnit {
+	if len(g.pending) < 1 {
+		return nil
+	}
+	unit := &AccessUnit{NALs: g.pending}
+	g.pending = make([]*NALUnit, 0, 4)
+	return unit
+}
+
+// GroupAccessUnits groups nals (e.g. result of Encoder.Encode and Encoder.Flush) into AccessUnits
+func GroupAccessUnits(nals []*NALUnit) []*AccessUnit {
+	g := NewAccessUnitGrouper()
+	units := g.Push(nals...)
+	if unit := g.Flush(); unit != nil {
+		units = append(units, unit)
+	}
+	return units
+}
+
+// NALReader reads length prefixed NAL units,
+// e.g. stream written by concatenating NALUnit.Bytes()
+type NALReader struct {
+	r       io.Reader
+	pool    BufferPool
+	grouper *AccessUnitGrouper
+	eof     bool
+}
+
+func NewNALReader(r io.Reader) *NALReader {
+	return &NALReader{
+		r:       r,
+		pool:    newSimpleBufferPool(4 * 1024),
+		grouper: NewAccessUnitGrouper(),
+		eof:     false,
+	}
+}
+
+// ReadNAL returns the next NALUnit, io.EOF at the end of stream.
+// UserData, PTS and DTS of NALUnit are 0 because they are not part of the stream.
+func (r *NALReader) ReadNAL() (*NALUnit, error) {
+	header := [4]byte{}
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:])
+	if size < 1 {
+		return nil, ErrInvalidNAL
+	}
+
+	buf := r.pool.Get()
+	buf.Write(header[:])
+	if _, err := io.CopyN(buf, r.r, int64(size)); err != nil {
+		buf.Reset()
+		r.pool.Put(buf)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return r.newNALUnit(buf, size), nil
+}
+
+func (r *NALReader) newNALUnit(buf *bytes.Buffer, size uint32) *NALUnit {
+	return &NALUnit{
+		buffer:      buf,
+		size:        size,
+		nalUnitType: uint32(xvctype.ParseNALUnitType(buf.Bytes()[4])),
+		closed:      int32(0),
+		closeFunc: func() {
+			buf.Reset()
+			r.pool.Put(buf)
+		},
+		leakID: leakTracker.track(AllocNALUnit),
+	}
+}
+
+// ReadAccessUnit returns the next AccessUnit, io.EOF at the end of stream.
+func (r *NALReader) ReadAccessUnit() (*AccessUnit, error) {
+	for r.eof != true {
+		nal, err := r.ReadNAL()
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			r.eof = true
+			break
+		}
+		if units := r.grouper.Push(nal); 0 < len(units) {
+			return units[0], nil
+		}
+	}
+	if unit := r.grouper.Flush(); unit != nil {
+		return unit, nil
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,160 @@
+package xvc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func readAllNALs(t *testing.T, r *NALReader) []*NALUnit {
+	t.Helper()
+
+	nals := make([]*NALUnit, 0, 8)
+	for {
+		nal, err := r.ReadNAL()
+		if err != nil {
+			if err == io.EOF {
+				return nals
+			}
+			t.Fatalf("%+v", err)
+		}
+		nals = append(nals, nal)
+	}
+}
+
+func closeNALs(nals []*NALUnit) {
+	for _, nal := range nals {
+		nal.Close()
+	}
+}
+
+func TestNALReader(t *testing.T) {
+	sh := readTestdata(t, "nal_0_16.xvc")
+	iap := readTestdata(t, "nal_1_1.xvc")
+
+	t.Run("read", func(tt *testing.T) {
+		nals := readAllNALs(tt, NewNALReader(bytes.NewReader(bytes.Join([][]byte{sh, iap, iap}, nil))))
+		defer closeNALs(nals)
+
+		expect := [][]byte{sh, iap, iap}
+		if len(nals) != len(expect) {
+			tt.Fatalf("expect %d nals actual %d", len(expect), len(nals))
+		}
+		if nals[0].Type() != SegmentHeader || nals[1].Type() != IntraAccessPicture {
+			tt.Errorf("expect SegmentHeader, IntraAccessPicture actual %s, %s", nals[0].Type(), nals[1].Type())
+		}
+		for i := range expect {
+			if bytes.Equal(expect[i], nals[i].Bytes()) != true {
+				tt.Errorf("nal[%d] not same", i)
+			}
+		}
+	})
+	t.Run("zero length", func(tt *testing.T) {
+		r := NewNALReader(bytes.NewReader(append([]byte{0, 0, 0, 0}, sh...)))
+		if _, err := r.ReadNAL(); err != ErrInvalidNAL {
+			tt.Errorf("expect ErrInvalidNAL actual %v", err)
+		}
+	})
+	t.Run("truncated", func(tt *testing.T) {
+		for name, data := range map[string][]byte{
+			"header":  sh[:2],
+			"payload": sh[:len(sh)-1],
+		} {
+			r := NewNALReader(bytes.NewReader(data))
+			if _, err := r.ReadNAL(); err != io.ErrUnexpectedEOF {
+				tt.Errorf("%s: expect io.ErrUnexpectedEOF actual %v", name, err)
+			}
+		}
+	})
+	t.Run("empty", func(tt *testing.T) {
+		r := NewNALReader(bytes.NewReader(nil))
+		if _, err := r.ReadNAL(); err != io.EOF {
+			tt.Errorf("expect io.EOF actual %v", err)
+		}
+		if _, err := r.ReadAccessUnit(); err != io.EOF {
+			tt.Errorf("expect io.EOF actual %v", err)
+		}
+	})
+}
+
+func TestReadAccessUnit(t *testing.T) {
+	sh := readTestdata(t, "nal_0_16.xvc")
+	iap := readTestdata(t, "nal_1_1.xvc")
+
+	// SH IAP | IAP | SH IAP | trailing SH
+	r := NewNALReader(bytes.NewReader(bytes.Join([][]byte{sh, iap, iap, sh, iap, sh}, nil)))
+	expect := []struct {
+		nals          int
+		keyframe      bool
+		segmentHeader bool
+	}{
+		{2, true, true},
+		{1, true, false},
+		{2, true, true},
+		{1, false, true},
+	}
+	for i, e := range expect {
+		au, err := r.ReadAccessUnit()
+		if err != nil {
+			t.Fatalf("au[%d]: %+v", i, err)
+		}
+		if len(au.NALs) != e.nals {
+			t.Errorf("au[%d]: expect %d nals actual %d", i, e.nals, len(au.NALs))
+		}
+		if au.IsKeyframe() != e.keyframe {
+			t.Errorf("au[%d]: expect keyframe %v actual %v", i, e.keyframe, au.IsKeyframe())
+		}
+		if au.HasSegmentHeader() != e.segmentHeader {
+			t.Errorf("au[%d]: expect segment header %v actual %v", i, e.segmentHeader, au.HasSegmentHeader())
+		}
+		au.Close()
+	}
+	if _, err := r.ReadAccessUnit(); err != io.EOF {
+		t.Errorf("expect io.EOF actual %v", err)
+	}
+}
+
+func TestGroupAccessUnits(t *testing.T) {
+	stream := bytes.Join([][]byte{
+		testNAL(AccessUnitDelimiter, 2, 0), testNAL(SegmentHeader, 16, 0), testNAL(IntraAccessPicture, 100, 0),
+		testNAL(Sei, 8, 0), testNAL(PredictedPicture, 50, 1),
+		testNAL(PredictedPicture, 50, 2),
+		testNAL(SegmentHeader, 16, 1), testNAL(IntraAccessPicture, 100, 3),
+		testNAL(Sei, 8, 1),
+	}, nil)
+	nals := readAllNALs(t, NewNALReader(bytes.NewReader(stream)))
+	units := GroupAccessUnits(nals)
+	defer func() {
+		for _, au := range units {
+			au.Close()
+		}
+	}()
+
+	expect := []struct {
+		nals int
+		typ  NALUnitType
+	}{
+		{3, IntraAccessPicture},
+		{2, PredictedPicture},
+		{1, PredictedPicture},
+		{2, IntraAccessPicture},
+		{1, NALUnitType(0xff)}, // trailing non-picture NALs
+	}
+	if len(units) != len(expect) {
+		t.Fatalf("expect %d access units actual %d", len(expect), len(units))
+	}
+	for i, e := range expect {
+		if len(units[i].NALs) != e.nals {
+			t.Errorf("au[%d]: expect %d nals actual %d", i, e.nals, len(units[i].NALs))
+		}
+		if units[i].Type() != e.typ {
+			t.Errorf("au[%d]: expect %s actual %s", i, e.typ, units[i].Type())
+		}
+	}
+	if units[4].Picture() != nil {
+		t.Errorf("expect no picture in trailing access unit")
+	}
+	if bytes.Equal(units[0].Bytes(), stream[:len(units[0].Bytes())]) != true {
+		t.Errorf("expect bytes of access unit in stream order")
+	}
+}
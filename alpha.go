@@ -0,0 +1,281 @@
+package xvc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// splitAlpha returns 4:2:0 color planes (not premultiplied) and alpha planes of img,
+// alpha is stored in luma of the auxiliary stream and its chroma is neutral (128).
+func splitAlpha(img image.Image) (*image.YCbCr, *image.YCbCr) {
+	b := img.Bounds()
+	rect := image.Rect(0, 0, b.Dx(), b.Dy())
+	colorPlanes := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	alphaPlanes := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	for i := range alphaPlanes.Cb {
+		alphaPlanes.Cb[i] = 128
+		alphaPlanes.Cr[i] = 128
+	}
+
+	nrgba, isNRGBA := img.(*image.NRGBA)
+	for y := 0; y < rect.Dy(); y += 1 {
+		for x := 0; x < rect.Dx(); x += 1 {
+			var c color.NRGBA
+			if isNRGBA {
+				c = nrgba.NRGBAAt(b.Min.X+x, b.Min.Y+y)
+			} else {
+				c = color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			}
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			colorPlanes.Y[colorPlanes.YOffset(x, y)] = yy
+			alphaPlanes.Y[alphaPlanes.YOffset(x, y)] = c.A
+			// top-left sample of 2x2 block
+			if (x&1) == 0 && (y&1) == 0 {
+				colorPlanes.Cb[colorPlanes.COffset(x, y)] = cb
+				colorPlanes.Cr[colorPlanes.COffset(x, y)] = cr
+			}
+		}
+	}
+	return colorPlanes, alphaPlanes
+}
+
+// mergeAlpha reconstructs NRGBA from decoded color and alpha pictures
+func mergeAlpha(colorPlanes, alphaPlanes *image.YCbCr) *image.NRGBA {
+	rect := colorPlanes.Rect
+	dst := image.NewNRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y += 1 {
+		for x := rect.Min.X; x < rect.Max.X; x += 1 {
+			c := colorPlanes.YCbCrAt(x, y)
+			r, g, b := color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+			a := uint8(255)
+			if (image.Point{x, y}).In(alphaPlanes.Rect) {
+				a = alphaPlanes.Y[alphaPlanes.YOffset(x, y)]
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: r, G: g, B: b, A: a})
+		}
+	}
+	return dst
+}
+
+// hasAlpha returns whether img has non-opaque pixels
+func hasAlpha(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque() != true
+	}
+	return false
+}
+
+// AlphaEncoder encodes images with transparency (e.g. *image.NRGBA, *image.RGBA) into
+// a color stream and an auxiliary alpha stream with the same parameters.
+// both streams have the same structure, pictures of the streams correspond in order.
+type AlphaEncoder struct {
+	color  *Encoder
+	alpha  *Encoder
+	desync bool
+}
+
+// AlphaStreamError is the error of Encode of AlphaEncoder with the stream ("color" or "alpha") failed
+type AlphaStreamError struct {
+	Stream string
+	Err    error
+}
+
+func (e *AlphaStreamError) Error() string {
+	return fmt.Sprintf("%s stream: %s", e.Stream, e.Err.Error())
+}
+
+func (e *AlphaStreamError) Unwrap() error {
+	return e.Err
+}
+
+// CreateAlphaEncoder creates AlphaEncoder, images are encoded as 8bit 4:2:0,
+// EncoderParameterChromaFormat and EncoderParameterInputBitDepth in funcs are overridden.
+func CreateAlphaEncoder(funcs ...encoderParameterFunc) (*AlphaEncoder, error) {
+	params := append([]encoderParameterFunc{}, funcs...)
+	params = append(params,
+		// planes of splitAlpha
+		EncoderParameterChromaFormat(ChromaFormat420),
+		EncoderParameterInputBitDepth(8),
+	)
+	colorEncoder, err := CreateEncoder(params...)
+	if err != nil {
+		return nil, err
+	}
+	alphaEncoder, err := CreateEncoder(params...)
+	if err != nil {
+		DestroyEncoder(colorEncoder)
+		return nil, err
+	}
+	return &AlphaEncoder{
+		color:  colorEncoder,
+		alpha:  alphaEncoder,
+		desync: false,
+	}, nil
+}
+
+// Encode encodes img with userData and pts, returns NALs of the color stream and the alpha stream.
+// errors are *AlphaStreamError with the failed stream. a picture encoded into the color stream can not be
+// rolled back, if the alpha stream failed, the streams are out of sync and subsequent Encode returns ErrAlphaDesync.
+func (e *AlphaEncoder) Encode(img image.Image, userData, pts int64) ([]*NALUnit, []*NALUnit, error) {
+	if e.desync {
+		return nil, nil, ErrAlphaDesync
+	}
+	colorPlanes, alphaPlanes := splitAlpha(img)
+	opt := EncodeOptions{PTS: pts}
+
+	colorNALs, err := e.color.EncodeWithOptions(colorPlanes.Y, colorPlanes.Cb, colorPlanes.Cr, colorPlanes.YStride, colorPlanes.CStride, colorPlanes.CStride, userData, opt)
+	if err != nil {
+		return nil, nil, &AlphaStreamError{Stream: "color", Err: err}
+	}
+	alphaNALs, err := e.alpha.EncodeWithOptions(alphaPlanes.Y, alphaPlanes.Cb, alphaPlanes.Cr, alphaPlanes.YStride, alphaPlanes.CStride, alphaPlanes.CStride, userData, opt)
+	if err != nil {
+		for _, nal := range colorNALs {
+			nal.Close()
+		}
+		e.desync = true
+		return nil, nil, &AlphaStreamError{Stream: "alpha", Err: err}
+	}
+	return colorNALs, alphaNALs, nil
+}
+
+// Flush returns the remaining NALs of the color stream and the alpha stream
+func (e *AlphaEncoder) Flush() ([]*NALUnit, []*NALUnit) {
+	colorNALs, _ := e.color.Flush()
+	alphaNALs, _ := e.alpha.Flush()
+	return colorNALs, alphaNALs
+}
+
+func DestroyAlphaEncoder(e *AlphaEncoder) error {
+	err := DestroyEncoder(e.color)
+	if aerr := DestroyEncoder(e.alpha); aerr != nil && err == nil {
+		err = aerr
+	}
+	return err
+}
+
+// AlphaPicture is a decoded picture with transparency, Image is owned by the caller.
+type AlphaPicture struct {
+	Image    *image.NRGBA
+	UserData int64
+	PTS      int64
+}
+
+// AlphaDecoder decodes a color stream and an auxiliary alpha stream of AlphaEncoder
+type AlphaDecoder struct {
+	color  *Decoder
+	alpha  *Decoder
+	colors []alphaQueueItem
+	alphas []*image.YCbCr
+}
+
+type alphaQueueItem struct {
+	img      *image.YCbCr
+	userData int64
+	pts      int64
+}
+
+func CreateAlphaDecoder(funcs ...decoderParameterFunc) (*AlphaDecoder, error) {
+	colorDecoder, err := CreateDecoder(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	alphaDecoder, err := CreateDecoder(funcs...)
+	if err != nil {
+		DestroyDecoder(colorDecoder)
+		return nil, err
+	}
+	return &AlphaDecoder{
+		color:  colorDecoder,
+		alpha:  alphaDecoder,
+		colors: make([]alphaQueueItem, 0, 4),
+		alphas: make([]*image.YCbCr, 0, 4),
+	}, nil
+}
+
+// DecodeColor decodes a NAL of the color stream
+func (d *AlphaDecoder) DecodeColor(nalData []byte) error {
+	return d.color.Decode(nalData)
+}
+
+// DecodeAlpha decodes a NAL of the alpha stream
+func (d *AlphaDecoder) DecodeAlpha(nalData []byte) error {
+	return d.alpha.Decode(nalData)
+}
+
+// DecodeNALUnits decodes NALs of Encode/Flush of AlphaEncoder, UserData and PTS are propagated to AlphaPicture.
+func (d *AlphaDecoder) DecodeNALUnits(colorNALs, alphaNALs []*NALUnit) error {
+	for _, nal := range colorNALs {
+		if err := d.color.DecodeNALUnit(nal); err != nil {
+			return err
+		}
+	}
+	for _, nal := range alphaNALs {
+		if err := d.alpha.DecodeNALUnit(nal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *AlphaDecoder) Flush() bool {
+	colorOK := d.color.Flush()
+	alphaOK := d.alpha.Flush()
+	return colorOK && alphaOK
+}
+
+// DecodedPicture returns a picture when both the color and alpha pictures are decoded,
+// DecReturnCode(DecNoDecodedPic) otherwise. ErrUnsupportedChromaFormat is returned for monochrome streams.
+func (d *AlphaDecoder) DecodedPicture() (*AlphaPicture, error) {
+	for {
+		pic, err := d.color.DecodedPicture()
+		if err != nil {
+			if err == DecReturnCode(DecNoDecodedPic) {
+				break
+			}
+			return nil, err
+		}
+		img, ok := pic.Image().(*image.YCbCr)
+		if ok != true {
+			pic.Close()
+			return nil, ErrUnsupportedChromaFormat
+		}
+		d.colors = append(d.colors, alphaQueueItem{cloneYCbCr(img), pic.UserData(), pic.PTS()})
+		pic.Close()
+	}
+	for {
+		pic, err := d.alpha.DecodedPicture()
+		if err != nil {
+			if err == DecReturnCode(DecNoDecodedPic) {
+				break
+			}
+			return nil, err
+		}
+		img, ok := pic.Image().(*image.YCbCr)
+		if ok != true {
+			pic.Close()
+			return nil, ErrUnsupportedChromaFormat
+		}
+		d.alphas = append(d.alphas, cloneYCbCr(img))
+		pic.Close()
+	}
+
+	if len(d.colors) < 1 || len(d.alphas) < 1 {
+		return nil, DecReturnCode(DecNoDecodedPic)
+	}
+	c, a := d.colors[0], d.alphas[0]
+	d.colors, d.alphas = d.colors[1:], d.alphas[1:]
+	return &AlphaPicture{
+		Image:    mergeAlpha(c.img, a),
+		UserData: c.userData,
+		PTS:      c.pts,
+	}, nil
+}
+
+func DestroyAlphaDecoder(d *AlphaDecoder) error {
+	err := DestroyDecoder(d.color)
+	if aerr := DestroyDecoder(d.alpha); aerr != nil && err == nil {
+		err = aerr
+	}
+	return err
+}
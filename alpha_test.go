@@ -0,0 +1,88 @@
+package xvc
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSplitMergeAlpha(t *testing.T) {
+	tests := []struct {
+		name  string
+		rect  image.Rectangle
+		color color.NRGBA
+	}{
+		{"even", image.Rect(0, 0, 8, 6), color.NRGBA{R: 100, G: 100, B: 100}},
+		{"odd", image.Rect(0, 0, 5, 3), color.NRGBA{R: 200, G: 40, B: 90}},
+		{"odd offset", image.Rect(3, 7, 10, 12), color.NRGBA{R: 10, G: 220, B: 30}},
+		{"single pixel", image.Rect(0, 0, 1, 1), color.NRGBA{R: 0, G: 0, B: 255}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(tt *testing.T) {
+			src := image.NewNRGBA(tc.rect)
+			for y := tc.rect.Min.Y; y < tc.rect.Max.Y; y += 1 {
+				for x := tc.rect.Min.X; x < tc.rect.Max.X; x += 1 {
+					c := tc.color
+					c.A = uint8((x*37 + y*11) % 256)
+					src.SetNRGBA(x, y, c)
+				}
+			}
+
+			colorPlanes, alphaPlanes := splitAlpha(src)
+			size := tc.rect.Size()
+			if colorPlanes.Rect.Size() != size || alphaPlanes.Rect.Size() != size {
+				tt.Fatalf("expect planes of %v actual %v %v", size, colorPlanes.Rect, alphaPlanes.Rect)
+			}
+			for i := range alphaPlanes.Cb {
+				if alphaPlanes.Cb[i] != 128 || alphaPlanes.Cr[i] != 128 {
+					tt.Fatalf("expect neutral chroma of alpha planes")
+				}
+			}
+
+			dst := mergeAlpha(colorPlanes, alphaPlanes)
+			for y := 0; y < size.Y; y += 1 {
+				for x := 0; x < size.X; x += 1 {
+					expect := src.NRGBAAt(tc.rect.Min.X+x, tc.rect.Min.Y+y)
+					actual := dst.NRGBAAt(x, y)
+					if expect.A != actual.A {
+						tt.Errorf("(%d,%d): expect alpha %d actual %d", x, y, expect.A, actual.A)
+					}
+					if absDiff(expect.R, actual.R) > 2 || absDiff(expect.G, actual.G) > 2 || absDiff(expect.B, actual.B) > 2 {
+						tt.Errorf("(%d,%d): expect %v actual %v", x, y, expect, actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func absDiff(a, b uint8) int {
+	if a < b {
+		return int(b - a)
+	}
+	return int(a - b)
+}
+
+func TestAlphaEncoderDesync(t *testing.T) {
+	t.Run("stream error", func(tt *testing.T) {
+		err := error(&AlphaStreamError{Stream: "alpha", Err: ErrFrameSizeMismatch})
+		if errors.Is(err, ErrFrameSizeMismatch) != true {
+			tt.Errorf("expect unwrap to ErrFrameSizeMismatch")
+		}
+		if err.Error() != "alpha stream: "+ErrFrameSizeMismatch.Error() {
+			tt.Errorf("expect failed stream in message actual %s", err.Error())
+		}
+	})
+	t.Run("desync", func(tt *testing.T) {
+		e := &AlphaEncoder{desync: true}
+		colorNALs, alphaNALs, err := e.Encode(image.NewNRGBA(image.Rect(0, 0, 16, 16)), 0, 0)
+		if err != ErrAlphaDesync {
+			tt.Errorf("expect ErrAlphaDesync actual %v", err)
+		}
+		if colorNALs != nil || alphaNALs != nil {
+			tt.Errorf("expect no nals")
+		}
+	})
+}
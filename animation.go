@@ -0,0 +1,297 @@
+package xvc
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"sort"
+	"time"
+)
+
+const (
+	// gifMinDelay is the delay applied to GIF frames with delay 0 or 1 (1/100s), same as browsers
+	gifMinDelay time.Duration = 100 * time.Millisecond
+)
+
+var (
+	// DefaultAnimationBackground is the color transparent pixels are composited onto by EncodeAnimation
+	DefaultAnimationBackground color.Color = color.White
+)
+
+// AnimationFrame is a frame of animated image with display duration
+type AnimationFrame struct {
+	Image image.Image
+	Delay time.Duration
+}
+
+// GIFFrames composites frames of g onto the logical screen honoring disposal methods,
+// returns the full canvas of each frame (*image.NRGBA) with delays.
+func GIFFrames(g *gif.GIF) []AnimationFrame {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		for _, img := range g.Image {
+			bounds = bounds.Union(img.Bounds())
+		}
+	}
+
+	background := image.Image(image.Transparent)
+	if p, ok := g.Config.ColorModel.(color.Palette); ok && int(g.BackgroundIndex) < len(p) {
+		if _, _, _, a := p[g.BackgroundIndex].RGBA(); a == 0xffff {
+			background = image.NewUniform(p[g.BackgroundIndex])
+		}
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	draw.Draw(canvas, bounds, background, image.Point{}, draw.Src)
+
+	frames := make([]AnimationFrame, 0, len(g.Image))
+	for i, img := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		frames = append(frames, AnimationFrame{
+			Image: cloneNRGBA(canvas),
+			Delay: gifDelay(g, i),
+		})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), background, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+func gifDelay(g *gif.GIF, i int) time.Duration {
+	if len(g.Delay) <= i || g.Delay[i] <= 1 {
+		return gifMinDelay
+	}
+	return time.Duration(g.Delay[i]) * 10 * time.Millisecond
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	dst := &image.NRGBA{
+		Pix:    make([]byte, len(img.Pix)),
+		Stride: img.Stride,
+		Rect:   img.Rect,
+	}
+	copy(dst.Pix, img.Pix)
+	return dst
+}
+
+// EncodeGIF encodes the composited frames of g, transparent pixels are composited onto
+// the background color of g if it is opaque, DefaultAnimationBackground otherwise.
+func EncodeGIF(g *gif.GIF, funcs ...encoderParameterFunc) ([]*AccessUnit, error) {
+	return EncodeAnimationBackground(GIFFrames(g), gifBackground(g), funcs...)
+}
+
+func gifBackground(g *gif.GIF) color.Color {
+	if p, ok := g.Config.ColorModel.(color.Palette); ok && int(g.BackgroundIndex) < len(p) {
+		if _, _, _, a := p[g.BackgroundIndex].RGBA(); a == 0xffff {
+			return p[g.BackgroundIndex]
+		}
+	}
+	return DefaultAnimationBackground
+}
+
+// EncodeAnimation encodes frames of the same size, PTS of a frame is the sum of the preceding delays
+// in the Timebase of the Encoder. framerate is set from the average delay unless overridden by funcs.
+// transparent pixels are composited onto DefaultAnimationBackground, use AlphaEncoder to keep transparency.
+// frames are encoded as 8bit 4:2:0, ErrFlushFailed is returned if the Encoder could not be flushed.
+// returns AccessUnits in decoding order, they can be written to a container (mp4, mkv, mpegts) with PTS/DTS.
+func EncodeAnimation(frames []AnimationFrame, funcs ...encoderParameterFunc) ([]*AccessUnit, error) {
+	return EncodeAnimationBackground(frames, DefaultAnimationBackground, funcs...)
+}
+
+// EncodeAnimationBackground is EncodeAnimation compositing transparent pixels onto background,
+// alpha of background is ignored.
+func EncodeAnimationBackground(frames []AnimationFrame, background color.Color, funcs ...encoderParameterFunc) ([]*AccessUnit, error) {
+	if len(frames) < 1 {
+		return nil, nil
+	}
+	bounds := frames[0].Image.Bounds()
+
+	total := time.Duration(0)
+	for _, f := range frames {
+		total += f.Delay
+	}
+	framerate := float32(30.0)
+	if 0 < total {
+		framerate = float32(float64(len(frames)) / total.Seconds())
+	}
+
+	params := []encoderParameterFunc{
+		EncoderParameterFramerate(framerate),
+	}
+	params = append(params, funcs...)
+	params = append(params,
+		EncoderParameterWidth(bounds.Dx()),
+		EncoderParameterHeight(bounds.Dy()),
+		// planes of toYCbCr420
+		EncoderParameterChromaFormat(ChromaFormat420),
+		EncoderParameterInputBitDepth(8),
+	)
+	encoder, err := CreateEncoder(params...)
+	if err != nil {
+		return nil, err
+	}
+	defer DestroyEncoder(encoder)
+
+	tb := encoder.Timebase()
+	nals := make([]*NALUnit, 0, len(frames)*2)
+	closeAll := func() {
+		for _, nal := range nals {
+			nal.Close()
+		}
+	}
+
+	elapsed := time.Duration(0)
+	for i, f := range frames {
+		if f.Image.Bounds().Size() != bounds.Size() {
+			closeAll()
+			return nil, ErrFrameSizeMismatch
+		}
+		ycc := toYCbCr420(flatten(f.Image, background))
+		n, err := encoder.EncodeWithOptions(ycc.Y, ycc.Cb, ycc.Cr, ycc.YStride, ycc.CStride, ycc.CStride, int64(i), EncodeOptions{
+			PTS: tb.Ticks(elapsed),
+		})
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		nals = append(nals, n...)
+		elapsed += f.Delay
+	}
+	n, ok := encoder.Flush()
+	if ok != true {
+		closeAll()
+		return nil, ErrFlushFailed
+	}
+	nals = append(nals, n...)
+	return GroupAccessUnits(nals), nil
+}
+
+// flatten composites img onto opaque background, img is returned as is if it is opaque
+func flatten(img image.Image, background color.Color) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	bg := color.NRGBAModel.Convert(background).(color.NRGBA)
+	bg.A = 0xff
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// DecodeAnimation decodes AccessUnits of EncodeAnimation, returns frames (*image.YCbCr) in presentation order.
+// ErrUnsupportedChromaFormat is returned for monochrome streams.
+// delays are the PTS differences in tb, the last frame has the delay of the preceding frame.
+func DecodeAnimation(units []*AccessUnit, tb Timebase, funcs ...decoderParameterFunc) ([]AnimationFrame, error) {
+	if tb.Valid() != true {
+		return nil, ErrInvalidTimebase
+	}
+	decoder, err := CreateDecoder(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	defer DestroyDecoder(decoder)
+
+	type decoded struct {
+		img *image.YCbCr
+		pts int64
+	}
+	pictures := make([]decoded, 0, len(units))
+	drain := func() error {
+		for {
+			pic, err := decoder.DecodedPicture()
+			if err != nil {
+				if err == DecReturnCode(DecNoDecodedPic) {
+					return nil
+				}
+				return err
+			}
+			img, ok := pic.Image().(*image.YCbCr)
+			if ok != true {
+				pic.Close()
+				return ErrUnsupportedChromaFormat
+			}
+			// copy before the buffer is released to the pool
+			pictures = append(pictures, decoded{cloneYCbCr(img), pic.PTS()})
+			pic.Close()
+		}
+	}
+
+	for _, au := range units {
+		if err := au.Decode(decoder); err != nil {
+			return nil, err
+		}
+		if err := drain(); err != nil {
+			return nil, err
+		}
+	}
+	if decoder.Flush() != true {
+		return nil, ErrFlushFailed
+	}
+	if err := drain(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pictures, func(i, j int) bool {
+		return pictures[i].pts < pictures[j].pts
+	})
+	frames := make([]AnimationFrame, len(pictures))
+	for i, p := range pictures {
+		delay := gifMinDelay
+		switch {
+		case i+1 < len(pictures):
+			delay = tb.Duration(pictures[i+1].pts - p.pts)
+		case 0 < i:
+			delay = frames[i-1].Delay
+		}
+		frames[i] = AnimationFrame{Image: p.img, Delay: delay}
+	}
+	return frames, nil
+}
+
+// ToGIF quantizes frames to palette.Plan9 with Floyd-Steinberg dithering, delays are rounded to 1/100s.
+func ToGIF(frames []AnimationFrame) *gif.GIF {
+	g := &gif.GIF{
+		Image:    make([]*image.Paletted, len(frames)),
+		Delay:    make([]int, len(frames)),
+		Disposal: make([]byte, len(frames)),
+	}
+	for i, f := range frames {
+		b := f.Image.Bounds()
+		paletted := image.NewPaletted(b, palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, b, f.Image, b.Min)
+
+		delay := int((f.Delay + 5*time.Millisecond) / (10 * time.Millisecond))
+		if delay < 2 {
+			delay = 2
+		}
+		g.Image[i] = paletted
+		g.Delay[i] = delay
+		g.Disposal[i] = gif.DisposalNone
+		if g.Config.Width < b.Max.X {
+			g.Config.Width = b.Max.X
+		}
+		if g.Config.Height < b.Max.Y {
+			g.Config.Height = b.Max.Y
+		}
+	}
+	return g
+}
@@ -0,0 +1,170 @@
+package xvc
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	p := color.Palette{
+		color.NRGBA{R: 0, G: 0, B: 0, A: 0},
+		color.NRGBA{R: 255, G: 0, B: 0, A: 255},
+		color.NRGBA{R: 0, G: 0, B: 255, A: 255},
+	}
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), p)
+	frame.SetColorIndex(1, 1, 1) // others transparent
+
+	t.Run("gif background", func(tt *testing.T) {
+		g := &gif.GIF{
+			Image:           []*image.Paletted{frame},
+			Delay:           []int{10},
+			Config:          image.Config{ColorModel: p, Width: 4, Height: 4},
+			BackgroundIndex: 2,
+		}
+		bg := gifBackground(g)
+		img := flatten(GIFFrames(g)[0].Image, bg)
+		if c := color.NRGBAModel.Convert(img.At(0, 0)); c != p[2] {
+			tt.Errorf("expect background color %v actual %v", p[2], c)
+		}
+		if c := color.NRGBAModel.Convert(img.At(1, 1)); c != p[1] {
+			tt.Errorf("expect frame color %v actual %v", p[1], c)
+		}
+	})
+	t.Run("transparent gif background", func(tt *testing.T) {
+		g := &gif.GIF{
+			Image:           []*image.Paletted{frame},
+			Delay:           []int{10},
+			Config:          image.Config{ColorModel: p, Width: 4, Height: 4},
+			BackgroundIndex: 0,
+		}
+		bg := gifBackground(g)
+		if bg != DefaultAnimationBackground {
+			tt.Errorf("expect DefaultAnimationBackground actual %v", bg)
+		}
+		img := flatten(GIFFrames(g)[0].Image, bg)
+		if c := color.NRGBAModel.Convert(img.At(0, 0)); c != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+			tt.Errorf("expect white actual %v", c)
+		}
+	})
+	t.Run("background alpha ignored", func(tt *testing.T) {
+		img := flatten(frame, color.NRGBA{R: 0, G: 255, B: 0, A: 0})
+		if c := color.NRGBAModel.Convert(img.At(0, 0)); c != (color.NRGBA{R: 0, G: 255, B: 0, A: 255}) {
+			tt.Errorf("expect opaque green actual %v", c)
+		}
+	})
+	t.Run("opaque", func(tt *testing.T) {
+		ycc := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+		if img := flatten(ycc, color.White); img != image.Image(ycc) {
+			tt.Errorf("expect opaque image as is")
+		}
+	})
+}
+
+func TestGIFFrames(t *testing.T) {
+	transparent := color.NRGBA{}
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	p := color.Palette{transparent, red, blue, green}
+
+	fill := func(r image.Rectangle, index uint8) *image.Paletted {
+		img := image.NewPaletted(r, p)
+		for i := range img.Pix {
+			img.Pix[i] = index
+		}
+		return img
+	}
+
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			fill(image.Rect(0, 0, 4, 4), 1),
+			fill(image.Rect(0, 0, 2, 2), 2),
+			fill(image.Rect(2, 2, 4, 4), 2),
+			fill(image.Rect(0, 3, 1, 4), 2),
+		},
+		Delay:           []int{10, 10, 10, 10},
+		Disposal:        []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalBackground, gif.DisposalNone},
+		Config:          image.Config{ColorModel: p, Width: 4, Height: 4},
+		BackgroundIndex: 3,
+	}
+	frames := GIFFrames(g)
+	if len(frames) != 4 {
+		t.Fatalf("expect 4 frames actual %d", len(frames))
+	}
+
+	tests := []struct {
+		frame  int
+		x, y   int
+		expect color.NRGBA
+	}{
+		{0, 0, 0, red},
+		{1, 0, 0, blue},
+		{1, 3, 3, red},
+		{2, 0, 0, red},   // restored by DisposalPrevious
+		{2, 2, 2, blue},  // drawn
+		{3, 2, 2, green}, // cleared to background by DisposalBackground
+		{3, 3, 3, green},
+		{3, 0, 3, blue},
+		{3, 1, 1, red},
+	}
+	for _, tc := range tests {
+		if c := color.NRGBAModel.Convert(frames[tc.frame].Image.At(tc.x, tc.y)); c != tc.expect {
+			t.Errorf("frame[%d] (%d,%d): expect %v actual %v", tc.frame, tc.x, tc.y, tc.expect, c)
+		}
+	}
+}
+
+func TestGIFDelay(t *testing.T) {
+	g := &gif.GIF{
+		Image: make([]*image.Paletted, 5),
+		Delay: []int{0, 1, 2, 7},
+	}
+	expect := []time.Duration{
+		gifMinDelay,
+		gifMinDelay,
+		20 * time.Millisecond,
+		70 * time.Millisecond,
+		gifMinDelay, // missing delay
+	}
+	for i, e := range expect {
+		if d := gifDelay(g, i); d != e {
+			t.Errorf("delay[%d]: expect %s actual %s", i, e, d)
+		}
+	}
+}
+
+func TestToGIF(t *testing.T) {
+	tests := []struct {
+		delay  time.Duration
+		expect int
+	}{
+		{0, 2},
+		{14 * time.Millisecond, 2},
+		{25 * time.Millisecond, 3},
+		{34 * time.Millisecond, 3},
+		{35 * time.Millisecond, 4},
+		{time.Second, 100},
+	}
+	frames := make([]AnimationFrame, len(tests))
+	for i, tc := range tests {
+		frames[i] = AnimationFrame{
+			Image: image.NewYCbCr(image.Rect(0, 0, 6, 4), image.YCbCrSubsampleRatio420),
+			Delay: tc.delay,
+		}
+	}
+	g := ToGIF(frames)
+	if g.Config.Width != 6 || g.Config.Height != 4 {
+		t.Errorf("expect 6x4 actual %dx%d", g.Config.Width, g.Config.Height)
+	}
+	for i, tc := range tests {
+		if g.Delay[i] != tc.expect {
+			t.Errorf("%s: expect %d actual %d", tc.delay, tc.expect, g.Delay[i])
+		}
+		if g.Disposal[i] != gif.DisposalNone {
+			t.Errorf("expect DisposalNone actual %d", g.Disposal[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package bench provides synthetic content and reports of the xvc binding performance.
+//
+// testing.B benchmarks over DefaultConfigs are run by:
+//
+//	go test -bench . ./bench
+package bench
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/octu0/go-xvc"
+)
+
+// Config is the encoder configuration of benchmark
+type Config struct {
+	Width     int
+	Height    int
+	SpeedMode int // 0: Placebo, 1: Slow, 2: Fast
+	Threads   int // -1: auto-detect
+	Pattern   Pattern
+}
+
+func (c Config) String() string {
+	return fmt.Sprintf("%dx%d/speed=%d/threads=%d/%s", c.Width, c.Height, c.SpeedMode, c.Threads, c.Pattern)
+}
+
+// Configs returns the combinations of resolutions, speed modes and thread counts
+func Configs(resolutions [][2]int, speedModes []int, threads []int) []Config {
+	configs := make([]Config, 0, len(resolutions)*len(speedModes)*len(threads))
+	for _, r := range resolutions {
+		for _, s := range speedModes {
+			for _, t := range threads {
+				configs = append(configs, Config{
+					Width:     r[0],
+					Height:    r[1],
+					SpeedMode: s,
+					Threads:   t,
+					Pattern:   PatternMovingSquare,
+				})
+			}
+		}
+	}
+	return configs
+}
+
+// DefaultConfigs returns 360p/720p/1080p x Slow/Fast x single/auto threads
+func DefaultConfigs() []Config {
+	return Configs(
+		[][2]int{{640, 360}, {1280, 720}, {1920, 1080}},
+		[]int{1, 2},
+		[]int{1, -1},
+	)
+}
+
+func (c Config) createEncoder() (*xvc.Encoder, error) {
+	return xvc.CreateEncoder(
+		xvc.EncoderParameterWidth(c.Width),
+		xvc.EncoderParameterHeight(c.Height),
+		xvc.EncoderParameterFramerate(30.0),
+		xvc.EncoderParameterSpeedMode(c.SpeedMode),
+		xvc.EncoderParameterThreads(c.Threads),
+	)
+}
+
+// frames of synthetic content reused in the loop
+const sourceFrames int = 30
+
+func (c Config) frames() []*image.YCbCr {
+	src := NewSource(c.Width, c.Height, image.YCbCrSubsampleRatio420, c.Pattern)
+	frames := make([]*image.YCbCr, sourceFrames)
+	for i := range frames {
+		frames[i] = src.Frame(i)
+	}
+	return frames
+}
+
+func encodeFrame(encoder *xvc.Encoder, img *image.YCbCr, n int) ([]*xvc.NALUnit, error) {
+	return encoder.Encode(img.Y, img.Cb, img.Cr, img.YStride, img.CStride, img.CStride, int64(n))
+}
@@ -0,0 +1,233 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/octu0/go-xvc"
+)
+
+func skipIfNotAvailable(b *testing.B) {
+	if xvc.Available() != true {
+		b.Skip(xvc.ErrNotAvailable.Error())
+	}
+}
+
+func closeNALs(nals []*xvc.NALUnit) int {
+	size := 0
+	for _, nal := range nals {
+		size += len(nal.Bytes())
+		nal.Close()
+	}
+	return size
+}
+
+// encodedStream returns AccessUnits of encoded synthetic content
+func (c Config) encodedStream() ([]*xvc.AccessUnit, error) {
+	encoder, err := c.createEncoder()
+	if err != nil {
+		return nil, err
+	}
+	defer xvc.DestroyEncoder(encoder)
+
+	all := make([]*xvc.NALUnit, 0, sourceFrames*2)
+	for i, img := range c.frames() {
+		nals, err := encodeFrame(encoder, img, i)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, nals...)
+	}
+	if nals, ok := encoder.Flush(); ok {
+		all = append(all, nals...)
+	}
+	return xvc.GroupAccessUnits(all), nil
+}
+
+// runConfigs runs fn as sub-benchmarks of DefaultConfigs
+func runConfigs(b *testing.B, fn func(*testing.B, Config)) {
+	skipIfNotAvailable(b)
+
+	for _, c := range DefaultConfigs() {
+		c := c
+		b.Run(c.String(), func(bb *testing.B) {
+			fn(bb, c)
+		})
+	}
+}
+
+// BenchmarkEncode benchmarks Encoder.Encode per frame, reports encoded bytes/frame
+func BenchmarkEncode(b *testing.B) {
+	runConfigs(b, benchmarkEncode)
+}
+
+func benchmarkEncode(b *testing.B, c Config) {
+	frames := c.frames()
+	encoder, err := c.createEncoder()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer xvc.DestroyEncoder(encoder)
+
+	size := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		nals, err := encodeFrame(encoder, frames[i%len(frames)], i)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size += closeNALs(nals)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(size)/float64(b.N), "bytes/frame")
+}
+
+// BenchmarkFlush benchmarks Encoder.Flush after encoding a sub-GOP of frames
+func BenchmarkFlush(b *testing.B) {
+	runConfigs(b, benchmarkFlush)
+}
+
+func benchmarkFlush(b *testing.B, c Config) {
+	frames := c.frames()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		b.StopTimer()
+		encoder, err := c.createEncoder()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for n := 0; n < 16; n += 1 {
+			nals, err := encodeFrame(encoder, frames[n%len(frames)], n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			closeNALs(nals)
+		}
+		b.StartTimer()
+
+		nals, _ := encoder.Flush()
+
+		b.StopTimer()
+		closeNALs(nals)
+		xvc.DestroyEncoder(encoder)
+		b.StartTimer()
+	}
+}
+
+// decodeLoop decodes the stream cyclically with a new decoder per cycle
+type decodeLoop struct {
+	units   []*xvc.AccessUnit
+	decoder *xvc.Decoder
+	next    int
+}
+
+func newDecodeLoop(b *testing.B, c Config) *decodeLoop {
+	units, err := c.encodedStream()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &decodeLoop{units: units}
+}
+
+func (l *decodeLoop) access() (*xvc.AccessUnit, error) {
+	if l.decoder == nil || len(l.units) <= l.next {
+		if l.decoder != nil {
+			l.decoder.Flush()
+			l.drain()
+			xvc.DestroyDecoder(l.decoder)
+		}
+		decoder, err := xvc.CreateDecoder()
+		if err != nil {
+			return nil, err
+		}
+		l.decoder = decoder
+		l.next = 0
+	}
+	au := l.units[l.next]
+	l.next += 1
+	return au, nil
+}
+
+func (l *decodeLoop) drain() int {
+	n := 0
+	for {
+		pic, err := l.decoder.DecodedPicture()
+		if err != nil {
+			return n
+		}
+		pic.Close()
+		n += 1
+	}
+}
+
+func (l *decodeLoop) close() {
+	if l.decoder != nil {
+		xvc.DestroyDecoder(l.decoder)
+	}
+	for _, au := range l.units {
+		au.Close()
+	}
+}
+
+// BenchmarkDecode benchmarks Decoder.Decode per AccessUnit
+func BenchmarkDecode(b *testing.B) {
+	runConfigs(b, benchmarkDecode)
+}
+
+func benchmarkDecode(b *testing.B, c Config) {
+	loop := newDecodeLoop(b, c)
+	defer loop.close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		b.StopTimer()
+		au, err := loop.access()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		for _, nal := range au.Split() {
+			if err := loop.decoder.Decode(nal); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.StopTimer()
+		loop.drain()
+		b.StartTimer()
+	}
+}
+
+// BenchmarkDecodedPicture benchmarks Decoder.DecodedPicture (copy of the picture into Go memory)
+func BenchmarkDecodedPicture(b *testing.B) {
+	runConfigs(b, benchmarkDecodedPicture)
+}
+
+func benchmarkDecodedPicture(b *testing.B, c Config) {
+	loop := newDecodeLoop(b, c)
+	defer loop.close()
+
+	pictures := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		b.StopTimer()
+		au, err := loop.access()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, nal := range au.Split() {
+			if err := loop.decoder.Decode(nal); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StartTimer()
+
+		pictures += loop.drain()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(pictures)/float64(b.N), "pictures/op")
+}
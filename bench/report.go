@@ -0,0 +1,160 @@
+package bench
+
+import (
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/octu0/go-xvc"
+)
+
+// Latency is the percentiles of per-frame duration
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+func newLatency(samples []time.Duration) Latency {
+	if len(samples) < 1 {
+		return Latency{}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	at := func(p float64) time.Duration {
+		return samples[int(float64(len(samples)-1)*p)]
+	}
+	return Latency{
+		P50: at(0.50),
+		P90: at(0.90),
+		P99: at(0.99),
+		Max: samples[len(samples)-1],
+	}
+}
+
+// Report is the result of Run
+type Report struct {
+	Config           string  `json:"config"`
+	Frames           int     `json:"frames"`
+	EncodeFPS        float64 `json:"encode_fps"`
+	DecodeFPS        float64 `json:"decode_fps"`
+	BytesPerFrame    float64 `json:"bytes_per_frame"`
+	EncodeAllocs     float64 `json:"encode_allocs_per_frame"`
+	EncodeAllocBytes float64 `json:"encode_alloc_bytes_per_frame"`
+	DecodeAllocs     float64 `json:"decode_allocs_per_frame"`
+	DecodeAllocBytes float64 `json:"decode_alloc_bytes_per_frame"`
+	EncodeLatency    Latency `json:"encode_latency"`
+	DecodeLatency    Latency `json:"decode_latency"`
+}
+
+type allocCounter struct {
+	mallocs    uint64
+	totalAlloc uint64
+}
+
+func startAllocCounter() allocCounter {
+	m := runtime.MemStats{}
+	runtime.ReadMemStats(&m)
+	return allocCounter{m.Mallocs, m.TotalAlloc}
+}
+
+func (a allocCounter) perFrame(frames int) (float64, float64) {
+	m := runtime.MemStats{}
+	runtime.ReadMemStats(&m)
+	return float64(m.Mallocs-a.mallocs) / float64(frames), float64(m.TotalAlloc-a.totalAlloc) / float64(frames)
+}
+
+// Run encodes and decodes frames of synthetic content with c, and reports
+// throughput, Go allocations, encoded size and latency percentiles per frame.
+// encode latency is the duration of Encode calls, decode latency is Decode and DecodedPicture of an AccessUnit.
+func Run(c Config, frames int) (Report, error) {
+	if xvc.Available() != true {
+		return Report{}, xvc.ErrNotAvailable
+	}
+
+	source := c.frames()
+	encoder, err := c.createEncoder()
+	if err != nil {
+		return Report{}, err
+	}
+	defer xvc.DestroyEncoder(encoder)
+
+	stream := make([]*xvc.NALUnit, 0, frames*2)
+	encodeLatency := make([]time.Duration, 0, frames)
+	size := 0
+
+	allocs := startAllocCounter()
+	start := time.Now()
+	for i := 0; i < frames; i += 1 {
+		t := time.Now()
+		nals, err := encodeFrame(encoder, source[i%len(source)], i)
+		encodeLatency = append(encodeLatency, time.Since(t))
+		if err != nil {
+			return Report{}, err
+		}
+		stream = append(stream, nals...)
+	}
+	if nals, ok := encoder.Flush(); ok {
+		stream = append(stream, nals...)
+	}
+	encodeElapsed := time.Since(start)
+	encAllocs, encAllocBytes := allocs.perFrame(frames)
+	for _, nal := range stream {
+		size += len(nal.Bytes())
+	}
+
+	units := xvc.GroupAccessUnits(stream)
+	defer func() {
+		for _, au := range units {
+			au.Close()
+		}
+	}()
+
+	decoder, err := xvc.CreateDecoder()
+	if err != nil {
+		return Report{}, err
+	}
+	defer xvc.DestroyDecoder(decoder)
+
+	decodeLatency := make([]time.Duration, 0, len(units))
+	drain := func() {
+		for {
+			pic, err := decoder.DecodedPicture()
+			if err != nil {
+				return
+			}
+			pic.Close()
+		}
+	}
+
+	allocs = startAllocCounter()
+	start = time.Now()
+	for _, au := range units {
+		t := time.Now()
+		if err := au.Decode(decoder); err != nil {
+			return Report{}, err
+		}
+		drain()
+		decodeLatency = append(decodeLatency, time.Since(t))
+	}
+	decoder.Flush()
+	drain()
+	decodeElapsed := time.Since(start)
+	decAllocs, decAllocBytes := allocs.perFrame(frames)
+
+	return Report{
+		Config:           c.String(),
+		Frames:           frames,
+		EncodeFPS:        float64(frames) / encodeElapsed.Seconds(),
+		DecodeFPS:        float64(frames) / decodeElapsed.Seconds(),
+		BytesPerFrame:    float64(size) / float64(frames),
+		EncodeAllocs:     encAllocs,
+		EncodeAllocBytes: encAllocBytes,
+		DecodeAllocs:     decAllocs,
+		DecodeAllocBytes: decAllocBytes,
+		EncodeLatency:    newLatency(encodeLatency),
+		DecodeLatency:    newLatency(decodeLatency),
+	}, nil
+}
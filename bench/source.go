@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"image"
+	"math/rand"
+)
+
+// Pattern is the synthetic content of Source
+type Pattern int
+
+const (
+	PatternGradient Pattern = iota
+	PatternNoise
+	PatternMovingSquare
+)
+
+func (p Pattern) String() string {
+	switch p {
+	case PatternGradient:
+		return "gradient"
+	case PatternNoise:
+		return "noise"
+	case PatternMovingSquare:
+		return "moving_square"
+	}
+	return "unknown"
+}
+
+// Source generates deterministic synthetic frames
+type Source struct {
+	width   int
+	height  int
+	ratio   image.YCbCrSubsampleRatio
+	pattern Pattern
+	rnd     *rand.Rand
+}
+
+func NewSource(width, height int, ratio image.YCbCrSubsampleRatio, pattern Pattern) *Source {
+	return &Source{
+		width:   width,
+		height:  height,
+		ratio:   ratio,
+		pattern: pattern,
+		rnd:     rand.New(rand.NewSource(int64(width*height) + int64(pattern))),
+	}
+}
+
+// Frame returns the n-th frame
+func (s *Source) Frame(n int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, s.width, s.height), s.ratio)
+	switch s.pattern {
+	case PatternGradient:
+		s.gradient(img, n)
+	case PatternNoise:
+		s.noise(img)
+	case PatternMovingSquare:
+		s.movingSquare(img, n)
+	}
+	return img
+}
+
+func (s *Source) gradient(img *image.YCbCr, n int) {
+	for y := 0; y < s.height; y += 1 {
+		row := img.Y[y*img.YStride : y*img.YStride+s.width]
+		for x := range row {
+			row[x] = uint8(((x + y + n) * 255) / (s.width + s.height))
+		}
+	}
+	cw, ch := img.CStride, len(img.Cb)/img.CStride
+	for y := 0; y < ch; y += 1 {
+		cb := img.Cb[y*img.CStride : y*img.CStride+cw]
+		cr := img.Cr[y*img.CStride : y*img.CStride+cw]
+		for x := range cb {
+			cb[x] = uint8(64 + ((x * 128) / cw))
+			cr[x] = uint8(64 + ((y * 128) / ch))
+		}
+	}
+}
+
+func (s *Source) noise(img *image.YCbCr) {
+	s.rnd.Read(img.Y)
+	s.rnd.Read(img.Cb)
+	s.rnd.Read(img.Cr)
+}
+
+func (s *Source) movingSquare(img *image.YCbCr, n int) {
+	for i := range img.Y {
+		img.Y[i] = 16
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 128
+		img.Cr[i] = 128
+	}
+	size := s.height / 4
+	if s.width < s.height {
+		size = s.width / 4
+	}
+	if size < 1 {
+		size = 1
+	}
+	x0 := (n * 4) % (s.width - size + 1)
+	y0 := (n * 2) % (s.height - size + 1)
+	for y := y0; y < y0+size; y += 1 {
+		for x := x0; x < x0+size; x += 1 {
+			img.Y[img.YOffset(x, y)] = 235
+			img.Cb[img.COffset(x, y)] = 90
+			img.Cr[img.COffset(x, y)] = 240
+		}
+	}
+}
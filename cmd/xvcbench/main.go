@@ -0,0 +1,119 @@
+// xvcbench measures encode/decode throughput, allocations, bytes per frame and latency percentiles
+// of the binding with synthetic content, to detect regressions.
+//
+//	xvcbench -res 640x360,1280x720 -speed 1,2 -threads 1,-1 -frames 120
+//
+// testing.B benchmarks of Encode, Flush, Decode and DecodedPicture are run by `go test -bench . ./bench`.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/octu0/go-xvc"
+	"github.com/octu0/go-xvc/bench"
+)
+
+func main() {
+	resolutions := flag.String("res", "640x360,1280x720,1920x1080", "comma separated resolutions WxH")
+	speedModes := flag.String("speed", "1,2", "comma separated speed modes (0: Placebo, 1: Slow, 2: Fast)")
+	threads := flag.String("threads", "1,-1", "comma separated thread counts (-1: auto-detect)")
+	frames := flag.Int("frames", 120, "frames per configuration")
+	format := flag.String("format", "text", "output format: text or json")
+	flag.Parse()
+
+	if xvc.Available() != true {
+		fmt.Fprintln(os.Stderr, "error:", xvc.ErrNotAvailable)
+		os.Exit(1)
+	}
+
+	configs, err := parseConfigs(*resolutions, *speedModes, *threads)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	reports := make([]bench.Report, 0, len(configs))
+	for _, c := range configs {
+		r, err := bench.Run(c, *frames)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", c, err)
+			os.Exit(1)
+		}
+		reports = append(reports, r)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+	default:
+		printReports(reports)
+	}
+}
+
+func printReports(reports []bench.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "config\tenc fps\tdec fps\tbytes/frame\tenc allocs\tdec allocs\tenc p50\tenc p99\tdec p50\tdec p99")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.0f\t%.1f\t%.1f\t%s\t%s\t%s\t%s\n",
+			r.Config,
+			r.EncodeFPS,
+			r.DecodeFPS,
+			r.BytesPerFrame,
+			r.EncodeAllocs,
+			r.DecodeAllocs,
+			r.EncodeLatency.P50,
+			r.EncodeLatency.P99,
+			r.DecodeLatency.P50,
+			r.DecodeLatency.P99,
+		)
+	}
+	w.Flush()
+}
+
+func parseConfigs(resolutions, speedModes, threads string) ([]bench.Config, error) {
+	res := make([][2]int, 0)
+	for _, r := range strings.Split(resolutions, ",") {
+		wh := strings.SplitN(strings.TrimSpace(r), "x", 2)
+		if len(wh) != 2 {
+			return nil, fmt.Errorf("invalid resolution: %s", r)
+		}
+		w, err := strconv.Atoi(wh[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolution: %s", r)
+		}
+		h, err := strconv.Atoi(wh[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolution: %s", r)
+		}
+		res = append(res, [2]int{w, h})
+	}
+	speeds, err := parseInts(speedModes)
+	if err != nil {
+		return nil, err
+	}
+	ths, err := parseInts(threads)
+	if err != nil {
+		return nil, err
+	}
+	return bench.Configs(res, speeds, ths), nil
+}
+
+func parseInts(s string) ([]int, error) {
+	values := make([]int, 0)
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", f)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,142 @@
+// xvccut trims and concatenates xvc streams (length prefixed NAL units) without re-encoding.
+//
+//	xvccut cut -from 300 -to 600 -o out.xvc in.xvc
+//	xvccut concat -o out.xvc a.xvc b.xvc c.xvc
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/octu0/go-xvc"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "cut":
+		err = cutCommand(os.Args[2:])
+	case "concat":
+		err = concatCommand(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  xvccut cut -from N -to M -o out.xvc in.xvc")
+	fmt.Fprintln(os.Stderr, "  xvccut concat -o out.xvc in1.xvc in2.xvc ...")
+}
+
+func cutCommand(args []string) error {
+	fs := flag.NewFlagSet("cut", flag.ExitOnError)
+	from := fs.Int64("from", 0, "first frame (rounded down to access point)")
+	to := fs.Int64("to", -1, "last frame exclusive (rounded up to access point), -1 to the end")
+	out := fs.String("o", "", "output file")
+	verify := fs.Bool("verify", true, "verify output decodes")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *out == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	end := *to
+	if end < 0 {
+		end = int64(^uint64(0) >> 1)
+	}
+	buf := bytes.NewBuffer(nil)
+	start, stop, err := xvc.Cut(buf, in, *from, end)
+	if err != nil {
+		return err
+	}
+	if *verify {
+		if err := verifyOutput(buf.Bytes(), stop-start); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(*out, buf); err != nil {
+		return err
+	}
+	fmt.Printf("cut frames [%d, %d) to %s\n", start, stop, *out)
+	return nil
+}
+
+func concatCommand(args []string) error {
+	fs := flag.NewFlagSet("concat", flag.ExitOnError)
+	out := fs.String("o", "", "output file")
+	verify := fs.Bool("verify", true, "verify output decodes")
+	fs.Parse(args)
+	if fs.NArg() < 1 || *out == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	streams := make([]io.Reader, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		streams = append(streams, f)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	frames, err := xvc.Concat(buf, streams...)
+	if err != nil {
+		return err
+	}
+	if *verify {
+		if err := verifyOutput(buf.Bytes(), frames); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(*out, buf); err != nil {
+		return err
+	}
+	fmt.Printf("concat %d streams, %d frames to %s\n", len(streams), frames, *out)
+	return nil
+}
+
+func verifyOutput(data []byte, frames int64) error {
+	decoded, err := xvc.Verify(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if decoded != frames {
+		return fmt.Errorf("verify: decoded %d pictures, expected %d", decoded, frames)
+	}
+	return nil
+}
+
+func writeFile(path string, buf *bytes.Buffer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
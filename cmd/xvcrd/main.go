@@ -0,0 +1,307 @@
+// xvcrd encodes a Y4M sequence at a list of QPs and presets, measures size and quality
+// of the decoded pictures, and reports rate-distortion points with Bjøntegaard delta rate.
+//
+//	xvcrd -i foreman.y4m -qp 22,27,32,37 -preset vod-high-quality,realtime-conferencing -format csv
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octu0/go-xvc"
+	"github.com/octu0/go-xvc/quality"
+)
+
+const defaultConfig string = "default"
+
+type rdResult struct {
+	Config    string  `json:"config"`
+	QP        int     `json:"qp"`
+	Frames    int     `json:"frames"`
+	Bytes     int64   `json:"bytes"`
+	Kbps      float64 `json:"kbps"`
+	PSNR      float64 `json:"psnr"`
+	PSNRY     float64 `json:"psnr_y"`
+	PSNRU     float64 `json:"psnr_u"`
+	PSNRV     float64 `json:"psnr_v"`
+	SSIMY     float64 `json:"ssim_y"`
+	MSSSIMY   float64 `json:"msssim_y"`
+	EncodeFPS float64 `json:"encode_fps"`
+}
+
+type bdResult struct {
+	Ref        string  `json:"ref"`
+	Test       string  `json:"test"`
+	BDRatePSNR float64 `json:"bdrate_psnr_y"`
+}
+
+type report struct {
+	Input   string     `json:"input"`
+	Results []rdResult `json:"results"`
+	BDRates []bdResult `json:"bdrates"`
+}
+
+func main() {
+	input := flag.String("i", "", "input y4m file")
+	qps := flag.String("qp", "22,27,32,37", "comma separated QPs")
+	presets := flag.String("preset", defaultConfig, "comma separated presets, \"default\" for default parameters. BD-rate is computed against the first")
+	maxFrames := flag.Int("frames", 0, "max frames to encode, 0 for all")
+	format := flag.String("format", "csv", "output format: csv or json")
+	output := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*input, *qps, *presets, *maxFrames, *format, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, qpList, presetList string, maxFrames int, format, output string) error {
+	qps, err := parseQPs(qpList)
+	if err != nil {
+		return err
+	}
+	configs := strings.Split(presetList, ",")
+
+	rep := report{Input: input}
+	for _, config := range configs {
+		for _, qp := range qps {
+			r, err := rdPoint(input, config, qp, maxFrames)
+			if err != nil {
+				return fmt.Errorf("%s qp=%d: %w", config, qp, err)
+			}
+			fmt.Fprintf(os.Stderr, "%s qp=%d %.2fkbps psnr=%.3f ssim=%.4f\n", config, qp, r.Kbps, r.PSNRY, r.SSIMY)
+			rep.Results = append(rep.Results, r)
+		}
+	}
+
+	for _, config := range configs[1:] {
+		bd, err := quality.BDRate(rdPoints(rep.Results, configs[0]), rdPoints(rep.Results, config))
+		if err != nil {
+			return fmt.Errorf("bd-rate %s: %w", config, err)
+		}
+		fmt.Fprintf(os.Stderr, "bd-rate %s vs %s: %+.2f%%\n", config, configs[0], bd)
+		rep.BDRates = append(rep.BDRates, bdResult{Ref: configs[0], Test: config, BDRatePSNR: bd})
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "csv":
+		return writeCSV(out, rep)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+func parseQPs(s string) ([]int, error) {
+	fields := strings.Split(s, ",")
+	qps := make([]int, 0, len(fields))
+	for _, f := range fields {
+		qp, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid qp: %s", f)
+		}
+		qps = append(qps, qp)
+	}
+	return qps, nil
+}
+
+func rdPoints(results []rdResult, config string) []quality.RDPoint {
+	points := make([]quality.RDPoint, 0, len(results))
+	for _, r := range results {
+		if r.Config == config {
+			points = append(points, quality.RDPoint{Rate: r.Kbps, Quality: r.PSNRY})
+		}
+	}
+	return points
+}
+
+// rdPoint encodes input with config and qp, decodes and compares with the source frames
+func rdPoint(input, config string, qp, maxFrames int) (rdResult, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return rdResult{}, err
+	}
+	defer f.Close()
+
+	y4m, err := newY4MReader(f)
+	if err != nil {
+		return rdResult{}, err
+	}
+
+	encoder, err := createEncoder(y4m, config, qp)
+	if err != nil {
+		return rdResult{}, err
+	}
+	defer xvc.DestroyEncoder(encoder)
+
+	decoder, err := xvc.CreateDecoder()
+	if err != nil {
+		return rdResult{}, err
+	}
+	defer xvc.DestroyDecoder(decoder)
+
+	stats := quality.NewStats(8)
+	pending := make(map[int64]*image.YCbCr, 32)
+	size := int64(0)
+	encodeTime := time.Duration(0)
+
+	compare := func() error {
+		for {
+			pic, err := decoder.DecodedPicture()
+			if err != nil {
+				return nil
+			}
+			src, ok := pending[pic.UserData()]
+			if ok {
+				delete(pending, pic.UserData())
+				result, err := quality.Compare(src, pic.Image())
+				if err != nil {
+					pic.Close()
+					return err
+				}
+				stats.Add(result)
+			}
+			pic.Close()
+		}
+	}
+	decode := func(nals []*xvc.NALUnit) error {
+		for _, nal := range nals {
+			size += int64(len(nal.Bytes()))
+			err := decoder.DecodeNALUnit(nal)
+			nal.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return compare()
+	}
+
+	frames := 0
+	for maxFrames < 1 || frames < maxFrames {
+		img, err := y4m.ReadFrame()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return rdResult{}, err
+		}
+		pending[int64(frames)] = img
+
+		t := time.Now()
+		nals, err := encoder.Encode(img.Y, img.Cb, img.Cr, img.YStride, img.CStride, img.CStride, int64(frames))
+		encodeTime += time.Since(t)
+		if err != nil {
+			return rdResult{}, err
+		}
+		if err := decode(nals); err != nil {
+			return rdResult{}, err
+		}
+		frames += 1
+	}
+
+	t := time.Now()
+	if nals, ok := encoder.Flush(); ok {
+		encodeTime += time.Since(t)
+		if err := decode(nals); err != nil {
+			return rdResult{}, err
+		}
+	}
+	decoder.Flush()
+	if err := compare(); err != nil {
+		return rdResult{}, err
+	}
+	if frames < 1 {
+		return rdResult{}, fmt.Errorf("no frames in %s", input)
+	}
+
+	summary := stats.Summary()
+	if len(summary.Planes) < 3 {
+		return rdResult{}, fmt.Errorf("no decoded pictures")
+	}
+	y, u, v := summary.Planes[0], summary.Planes[1], summary.Planes[2]
+	seconds := float64(frames) / float64(y4m.framerate)
+	return rdResult{
+		Config:    config,
+		QP:        qp,
+		Frames:    summary.Frames,
+		Bytes:     size,
+		Kbps:      float64(size*8) / seconds / 1000.0,
+		PSNR:      ((6.0 * y.MeanPSNR) + u.MeanPSNR + v.MeanPSNR) / 8.0,
+		PSNRY:     y.MeanPSNR,
+		PSNRU:     u.MeanPSNR,
+		PSNRV:     v.MeanPSNR,
+		SSIMY:     y.MeanSSIM,
+		MSSSIMY:   y.MeanMSSSIM,
+		EncodeFPS: float64(frames) / encodeTime.Seconds(),
+	}, nil
+}
+
+func createEncoder(y4m *y4mReader, config string, qp int) (*xvc.Encoder, error) {
+	if config == defaultConfig {
+		return xvc.CreateEncoder(
+			xvc.EncoderParameterWidth(y4m.width),
+			xvc.EncoderParameterHeight(y4m.height),
+			xvc.EncoderParameterFramerate(y4m.framerate),
+			xvc.EncoderParameterQP(qp),
+		)
+	}
+	// QP is applied after the preset
+	return xvc.CreateEncoder(
+		xvc.EncoderParameterWidth(y4m.width),
+		xvc.EncoderParameterHeight(y4m.height),
+		xvc.EncoderParameterFramerate(y4m.framerate),
+		xvc.EncoderPreset(config),
+		xvc.EncoderParameterQP(qp),
+	)
+}
+
+func writeCSV(out io.Writer, rep report) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"config", "qp", "frames", "bytes", "kbps", "psnr", "psnr_y", "psnr_u", "psnr_v", "ssim_y", "msssim_y", "encode_fps"})
+	for _, r := range rep.Results {
+		w.Write([]string{
+			r.Config,
+			strconv.Itoa(r.QP),
+			strconv.Itoa(r.Frames),
+			strconv.FormatInt(r.Bytes, 10),
+			formatFloat(r.Kbps),
+			formatFloat(r.PSNR),
+			formatFloat(r.PSNRY),
+			formatFloat(r.PSNRU),
+			formatFloat(r.PSNRV),
+			formatFloat(r.SSIMY),
+			formatFloat(r.MSSSIMY),
+			formatFloat(r.EncodeFPS),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
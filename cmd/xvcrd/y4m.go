@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidY4M     = errors.New("invalid y4m")
+	errUnsupportedY4M = errors.New("unsupported y4m colorspace, only 8bit 4:2:0 (C420, C420jpeg, C420paldv, C420mpeg2) is supported")
+)
+
+// y4mReader reads 8bit 4:2:0 frames of YUV4MPEG2, C tag defaults to 420jpeg.
+// chroma planes of odd width/height are (w+1)/2 x (h+1)/2, same as image.NewYCbCr and xvc.Decoder.
+type y4mReader struct {
+	r         *bufio.Reader
+	width     int
+	height    int
+	framerate float32
+}
+
+func newY4MReader(r io.Reader) (*y4mReader, error) {
+	br := bufio.NewReaderSize(r, 1024*1024)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 1 || fields[0] != "YUV4MPEG2" {
+		return nil, errInvalidY4M
+	}
+
+	y := &y4mReader{r: br, framerate: 30.0}
+	for _, f := range fields[1:] {
+		switch f[0] {
+		case 'W':
+			y.width, err = strconv.Atoi(f[1:])
+		case 'H':
+			y.height, err = strconv.Atoi(f[1:])
+		case 'F':
+			y.framerate, err = parseFramerate(f[1:])
+		case 'C':
+			switch f[1:] {
+			case "420", "420jpeg", "420paldv", "420mpeg2":
+				// 8bit 4:2:0
+			default:
+				// e.g. C444, C422, C420p10, Cmono
+				return nil, fmt.Errorf("%w: %s", errUnsupportedY4M, f)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidY4M, f)
+		}
+	}
+	if y.width < 1 || y.height < 1 {
+		return nil, errInvalidY4M
+	}
+	return y, nil
+}
+
+func parseFramerate(s string) (float32, error) {
+	parts := strings.SplitN(s, ":", 2)
+	num, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	den := 1
+	if len(parts) == 2 {
+		if den, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, err
+		}
+	}
+	if num < 1 || den < 1 {
+		return 0, errInvalidY4M
+	}
+	return float32(num) / float32(den), nil
+}
+
+// ReadFrame returns the next frame, io.EOF at the end of stream
+func (y *y4mReader) ReadFrame() (*image.YCbCr, error) {
+	line, err := y.r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) < 1 {
+			return nil, io.EOF
+		}
+		return nil, errInvalidY4M
+	}
+	if bytes.HasPrefix(line, []byte("FRAME")) != true {
+		return nil, errInvalidY4M
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, y.width, y.height), image.YCbCrSubsampleRatio420)
+	for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+		if _, err := io.ReadFull(y.r, plane); err != nil {
+			return nil, errInvalidY4M
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,88 @@
+// xvcroundtrip runs the round-trip integration suite against libxvc.
+// it exits successfully with SKIP when libxvc is not available.
+//
+//	go run -race ./cmd/xvcroundtrip -parallel 4 -leak
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/octu0/go-xvc"
+	"github.com/octu0/go-xvc/roundtrip"
+)
+
+func main() {
+	parallel := flag.Int("parallel", 2, "number of cases run concurrently")
+	filter := flag.String("run", "", "run only cases containing the string")
+	verbose := flag.Bool("v", false, "print passed cases")
+	leak := flag.Bool("leak", false, "fail if C allocations, NALUnit or DecodedPicture are outstanding after the suite")
+	flag.Parse()
+
+	if *leak {
+		xvc.EnableLeakDetection(true)
+	}
+
+	cases := make([]roundtrip.Case, 0)
+	for _, c := range roundtrip.Cases() {
+		if strings.Contains(c.String(), *filter) {
+			cases = append(cases, c)
+		}
+	}
+	if *parallel < 1 {
+		*parallel = 1
+	}
+
+	type outcome struct {
+		result roundtrip.Result
+		err    error
+	}
+	outcomes := make([]outcome, len(cases))
+
+	wg := new(sync.WaitGroup)
+	sem := make(chan struct{}, *parallel)
+	for i, c := range cases {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c roundtrip.Case) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r, err := roundtrip.Run(c)
+			outcomes[i] = outcome{r, err}
+		}(i, c)
+	}
+	wg.Wait()
+
+	failed, skipped := 0, 0
+	for i, o := range outcomes {
+		switch {
+		case o.err == roundtrip.ErrSkipped:
+			skipped += 1
+		case o.err != nil:
+			failed += 1
+			fmt.Printf("FAIL %s: %v\n", cases[i], o.err)
+		case *verbose:
+			fmt.Printf("PASS %s nals=%d pictures=%d bytes=%d min_psnr=%.2f\n", cases[i], o.result.NALs, o.result.Pictures, o.result.Bytes, o.result.MinPSNR)
+		}
+	}
+
+	if 0 < skipped {
+		fmt.Printf("SKIP %d cases: %v\n", skipped, roundtrip.ErrSkipped)
+	}
+	leaks := 0
+	if *leak {
+		for _, a := range xvc.OutstandingAllocations() {
+			leaks += 1
+			fmt.Printf("LEAK %s", a)
+		}
+	}
+	if 0 < failed || 0 < leaks {
+		fmt.Printf("FAIL %d/%d cases, %d leaks\n", failed, len(cases), leaks)
+		os.Exit(1)
+	}
+	fmt.Printf("ok %d cases\n", len(cases)-skipped)
+}
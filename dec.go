@@ -2,8 +2,6 @@ package xvc
 
 /*
 #cgo CFLAGS: -I${SRCDIR}/include -I/usr/local/include -I/usr/include
-#cgo darwin LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcdec -lc++
-#cgo linux  LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcdec -lc++
 #include <stdint.h>
 #include <stdlib.h>
 
@@ -14,11 +12,12 @@ import "C"
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"image"
 	"runtime"
 	"sync/atomic"
 	"unsafe"
+
+	"github.com/octu0/go-xvc/xvctype"
 )
 
 type DecodedPicture struct {
@@ -27,12 +26,15 @@ type DecodedPicture struct {
 	colorMatrix   ColorMatrix
 	img           image.Image
 	userData      int64
+	pts           int64
 	closed        int32
 	closeFunc     func()
+	leakID        uint64
 }
 
 func (n *DecodedPicture) Close() {
 	if atomic.CompareAndSwapInt32(&n.closed, 0, 1) {
+		leakTracker.release(n.leakID)
 		n.closeFunc()
 	}
 }
@@ -61,6 +63,11 @@ func (n *DecodedPicture) UserData() int64 {
 	return n.userData
 }
 
+// PTS returns presentation timestamp given by DecodeNALUnit
+func (n *DecodedPicture) PTS() int64 {
+	return n.pts
+}
+
 type decoderParameterFunc func(*decoderParameter)
 type decoderParameter struct {
 	width          int
@@ -135,6 +142,14 @@ func DecoderParameterMaxFramerate(rate float32) decoderParameterFunc {
 	}
 }
 
+// DecoderParameterChromaFormat sets the chroma format of DecodedPicture (default 4:2:0),
+// the image is *image.Gray for ChromaFormatMonochrome and *image.YCbCr otherwise.
+func DecoderParameterChromaFormat(format ChromaFormat) decoderParameterFunc {
+	return func(p *decoderParameter) {
+		p.chromaFormat = format
+	}
+}
+
 func DecoderBufferPool(fn func() BufferPool) decoderParameterFunc {
 	return func(p *decoderParameter) {
 		p.bufferPoolFunc = fn
@@ -145,9 +160,32 @@ type Decoder struct {
 	api     unsafe.Pointer // xvc_decoder_api*
 	decoder unsafe.Pointer // xvc_decoder*
 	pool    BufferPool
+	frames  *frameTable
+	leakID  uint64
 }
 
+// Decode decodes a NAL unit prefixed by its 4 byte little endian length,
+// ErrInvalidNAL is returned when the length is 0 or exceeds nalData.
 func (d *Decoder) Decode(nalData []byte) error {
+	return d.decode(nalData, frameInfo{})
+}
+
+// DecodeWithUserData decodes nalData and propagates userData to DecodedPicture,
+// e.g. capture timestamp or sequence number of the receiver.
+func (d *Decoder) DecodeWithUserData(nalData []byte, userData int64) error {
+	return d.decode(nalData, frameInfo{userData: userData})
+}
+
+// DecodeNALUnit decodes the NALUnit from Encoder,
+// UserData and PTS of nal are propagated to DecodedPicture.
+func (d *Decoder) DecodeNALUnit(nal *NALUnit) error {
+	return d.decode(nal.Bytes(), frameInfo{nal.UserData(), nal.PTS()})
+}
+
+func (d *Decoder) decode(nalData []byte, info frameInfo) error {
+	if len(nalData) < 4 {
+		return ErrInvalidNAL
+	}
 	r := bytes.NewReader(nalData[0:4])
 
 	nalSize := [4]uint8{}
@@ -156,15 +194,29 @@ func (d *Decoder) Decode(nalData []byte) error {
 	}
 
 	length := uint32(nalSize[0]) | uint32(nalSize[1])<<8 | uint32(nalSize[2])<<16 | uint32(nalSize[3])<<24
-	data := nalData[4:length]
+	if length == 0 || uint64(len(nalData)-4) < uint64(length) {
+		return ErrInvalidNAL
+	}
+	data := nalData[4 : 4+length]
+
+	// sequence number is passed to libxvc as user_data for pictures
+	seq := int64(0)
+	isPicture := xvctype.ParseNALUnitType(data[0]).IsPicture()
+	if isPicture {
+		seq = d.frames.put(info)
+	}
 
 	ret := C.decoder_decode_nal(
 		(*C.xvc_decoder_api)(d.api),
 		(*C.xvc_decoder)(d.decoder),
 		(*C.uchar)(unsafe.Pointer(&data[0])),
 		C.size_t(length),
-		C.int64_t(0),
+		C.int64_t(seq),
 	)
+	if ret != C.XVC_DEC_OK && isPicture {
+		// no picture will be output for seq
+		d.frames.take(seq)
+	}
 
 	switch ret {
 	case C.XVC_DEC_BITSTREAM_VERSION_LOWER_THAN_SUPPORTED_BY_DECODER,
@@ -186,6 +238,11 @@ func (d *Decoder) Flush() bool {
 	return true
 }
 
+// decoderPictureCount returns the number of pictures not destroyed by decoder_picture_destroy
+func decoderPictureCount() int64 {
+	return int64(C.decoder_picture_count())
+}
+
 func (d *Decoder) DecodedPicture() (*DecodedPicture, error) {
 	pic := C.decoder_picture_create(
 		(*C.xvc_decoder_api)(d.api),
@@ -210,7 +267,14 @@ func (d *Decoder) DecodedPicture() (*DecodedPicture, error) {
 	buf.Write(C.GoBytes(unsafe.Pointer(pic.bytes), C.int(pic.size)))
 
 	width, height := int(pic.stats.width), int(pic.stats.height)
-	img := d.createImage(buf, width, height, ChromaFormat(pic.stats.chroma_format))
+	img, err := d.createImage(buf, width, height, ChromaFormat(pic.stats.chroma_format))
+	if err != nil {
+		buf.Reset()
+		d.pool.Put(buf)
+		return nil, err
+	}
+
+	info, _ := d.frames.take(int64(pic.user_data))
 
 	dpic := &DecodedPicture{
 		width:       width,
@@ -218,65 +282,77 @@ func (d *Decoder) DecodedPicture() (*DecodedPicture, error) {
 		nalType:     NALUnitType(pic.stats.nal_unit_type),
 		colorMatrix: ColorMatrix(pic.stats.color_matrix),
 		img:         img,
-		userData:    int64(pic.user_data),
+		userData:    info.userData,
+		pts:         info.pts,
 		closed:      int32(0),
 		closeFunc: func() {
 			buf.Reset()
 			d.pool.Put(buf)
 		},
+		leakID: leakTracker.track(AllocDecodedPicture),
 	}
 	return dpic, nil
 }
 
-func (d *Decoder) createImage(buf *bytes.Buffer, width, height int, format ChromaFormat) image.Image {
+func (d *Decoder) createImage(buf *bytes.Buffer, width, height int, format ChromaFormat) (image.Image, error) {
 	switch format {
+	case ChromaFormatMonochrome:
+		return d.grayImage(buf, width, height)
 	case ChromaFormat420:
 		return d.yuvImage(buf, width, height, image.YCbCrSubsampleRatio420)
+	case ChromaFormat422:
+		return d.yuvImage(buf, width, height, image.YCbCrSubsampleRatio422)
 	case ChromaFormat444:
 		return d.yuvImage(buf, width, height, image.YCbCrSubsampleRatio444)
 	default:
-		panic(fmt.Sprintf("unsupport format: %d", format))
+		return nil, ErrUnsupportedChromaFormat
 	}
 }
 
-func (d *Decoder) yuvImage(buf *bytes.Buffer, width, height int, subsample image.YCbCrSubsampleRatio) *image.YCbCr {
-	rect := image.Rect(0, 0, width, height)
+func (d *Decoder) grayImage(buf *bytes.Buffer, width, height int) (*image.Gray, error) {
 	data := buf.Bytes()
+	if len(data) < width*height {
+		return nil, ErrInvalidPicture
+	}
+	return &image.Gray{
+		Pix:    data[0 : width*height],
+		Stride: width,
+		Rect:   image.Rect(0, 0, width, height),
+	}, nil
+}
 
+func (d *Decoder) yuvImage(buf *bytes.Buffer, width, height int, subsample image.YCbCrSubsampleRatio) (*image.YCbCr, error) {
+	// chroma planes of odd width/height are rounded up, same as image.NewYCbCr
+	cw, ch := width, height
 	switch subsample {
 	case image.YCbCrSubsampleRatio420:
-		ySize := width * height
-		uvSize := (width / 2) * (height / 2)
-		y0, y1 := 0, ySize
-		u0, u1 := ySize, ySize+uvSize
-		v0, v1 := ySize+uvSize, ySize+uvSize+uvSize
-		return &image.YCbCr{
-			Y:              data[y0:y1],
-			Cb:             data[u0:u1],
-			Cr:             data[v0:v1],
-			YStride:        width,
-			CStride:        width / 2,
-			Rect:           rect,
-			SubsampleRatio: subsample,
-		}
-	case image.YCbCrSubsampleRatio444:
-		ySize := width * height
-		uvSize := width * height
-		y0, y1 := 0, ySize
-		u0, u1 := ySize, ySize+uvSize
-		v0, v1 := ySize+uvSize, ySize+uvSize+uvSize
-		return &image.YCbCr{
-			Y:              data[y0:y1],
-			Cb:             data[u0:u1],
-			Cr:             data[v0:v1],
-			YStride:        width,
-			CStride:        width,
-			Rect:           rect,
-			SubsampleRatio: subsample,
-		}
-	default:
-		panic(fmt.Sprintf("unsupport yuv format: %d", subsample))
+		cw, ch = (width+1)/2, (height+1)/2
+	case image.YCbCrSubsampleRatio422:
+		cw, ch = (width+1)/2, height
+	}
+
+	data := buf.Bytes()
+	ySize := width * height
+	uvSize := cw * ch
+	if len(data) < ySize+uvSize+uvSize {
+		return nil, ErrInvalidPicture
 	}
+	y0, y1 := 0, ySize
+	u0, u1 := ySize, ySize+uvSize
+	v0, v1 := ySize+uvSize, ySize+uvSize+uvSize
+	return &image.YCbCr{
+		Y:              data[y0:y1],
+		Cb:             data[u0:u1],
+		Cr:             data[v0:v1],
+		YStride:        width,
+		CStride:        cw,
+		Rect:           image.Rect(0, 0, width, height),
+		SubsampleRatio: subsample,
+	}, nil
+}
+
+func decoderAvailable() bool {
+	return C.decoder_api_get() != nil
 }
 
 func CreateDecoder(funcs ...decoderParameterFunc) (*Decoder, error) {
@@ -286,6 +362,9 @@ func CreateDecoder(funcs ...decoderParameterFunc) (*Decoder, error) {
 	}
 
 	api := unsafe.Pointer(C.decoder_api_get())
+	if api == nil {
+		return nil, ErrNotAvailable
+	}
 	param := unsafe.Pointer(C.decoder_parameters_create(
 		(*C.xvc_decoder_api)(api),
 	))
@@ -317,7 +396,13 @@ func CreateDecoder(funcs ...decoderParameterFunc) (*Decoder, error) {
 		(*C.xvc_decoder_api)(api),
 		(*C.xvc_decoder_parameters)(param),
 	))
-	decoder := &Decoder{api, dec, decParam.bufferPoolFunc()}
+	decoder := &Decoder{
+		api:     api,
+		decoder: dec,
+		pool:    decParam.bufferPoolFunc(),
+		frames:  newFrameTable(),
+		leakID:  leakTracker.track(AllocDecoder),
+	}
 	runtime.SetFinalizer(decoder, finalizeDecoder)
 	return decoder, nil
 }
@@ -328,6 +413,7 @@ func finalizeDecoder(decoder *Decoder) {
 
 func DestroyDecoder(decoder *Decoder) error {
 	runtime.SetFinalizer(decoder, nil) // clear finalizer
+	leakTracker.release(decoder.leakID)
 
 	if ret := C.decoder_destroy(
 		(*C.xvc_decoder_api)(decoder.api),
@@ -5,9 +5,40 @@
 #ifndef H_GO_XVC_DEC
 #define H_GO_XVC_DEC
 
+#ifdef GO_XVC_DLOPEN
+#include <dlfcn.h>
+#include <pthread.h>
+
+#ifdef __APPLE__
+#define GO_XVC_DEC_LIBNAME "libxvcdec.dylib"
+#else
+#define GO_XVC_DEC_LIBNAME "libxvcdec.so"
+#endif
+
+// dlopen once, decoder_api_get is called concurrently by CreateDecoder
+static void *go_xvc_dec_handle = NULL;
+static pthread_once_t go_xvc_dec_once = PTHREAD_ONCE_INIT;
+
+static void go_xvc_dec_open(void) {
+  go_xvc_dec_handle = dlopen(GO_XVC_DEC_LIBNAME, RTLD_NOW | RTLD_GLOBAL);
+}
+
+const xvc_decoder_api* decoder_api_get() {
+  pthread_once(&go_xvc_dec_once, go_xvc_dec_open);
+  if(go_xvc_dec_handle == NULL) {
+    return NULL;
+  }
+  const xvc_decoder_api* (*api_get)(void) = (const xvc_decoder_api* (*)(void)) dlsym(go_xvc_dec_handle, "xvc_decoder_api_get");
+  if(api_get == NULL) {
+    return NULL;
+  }
+  return api_get();
+}
+#else
 const xvc_decoder_api* decoder_api_get() {
   return xvc_decoder_api_get();
 }
+#endif
 
 xvc_decoder_parameters* decoder_parameters_create(xvc_decoder_api* api) {
   return (api->parameters_create)();
@@ -33,11 +64,25 @@ xvc_dec_return_code decoder_destroy(xvc_decoder_api* api, xvc_decoder* decoder)
   return (api->decoder_destroy)(decoder);
 }
 
+// number of xvc_decoded_picture not destroyed yet, read by leak detection
+static int64_t go_xvc_decoder_picture_count = 0;
+
+int64_t decoder_picture_count() {
+  return __atomic_load_n(&go_xvc_decoder_picture_count, __ATOMIC_SEQ_CST);
+}
+
 xvc_decoded_picture* decoder_picture_create(xvc_decoder_api* api, xvc_decoder* decoder) {
-  return (api->picture_create)(decoder);
+  xvc_decoded_picture* pic = (api->picture_create)(decoder);
+  if(pic != NULL) {
+    __atomic_add_fetch(&go_xvc_decoder_picture_count, 1, __ATOMIC_SEQ_CST);
+  }
+  return pic;
 }
 
 xvc_dec_return_code decoder_picture_destroy(xvc_decoder_api* api, xvc_decoded_picture* pic) {
+  if(pic != NULL) {
+    __atomic_sub_fetch(&go_xvc_decoder_picture_count, 1, __ATOMIC_SEQ_CST);
+  }
   return (api->picture_destroy)(pic);
 }
 
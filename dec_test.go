@@ -0,0 +1,87 @@
+package xvc
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestYUVImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		subsample     image.YCbCrSubsampleRatio
+	}{
+		{"420 even", 16, 8, image.YCbCrSubsampleRatio420},
+		{"420 odd", 15, 7, image.YCbCrSubsampleRatio420},
+		{"420 1x1", 1, 1, image.YCbCrSubsampleRatio420},
+		{"422 odd", 15, 7, image.YCbCrSubsampleRatio422},
+		{"444 odd", 15, 7, image.YCbCrSubsampleRatio444},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(tt *testing.T) {
+			// planes laid out as image.NewYCbCr, which is how Encoder input is read
+			expect := image.NewYCbCr(image.Rect(0, 0, tc.width, tc.height), tc.subsample)
+			for i := range expect.Y {
+				expect.Y[i] = byte(i)
+			}
+			for i := range expect.Cb {
+				expect.Cb[i] = byte(100 + i)
+				expect.Cr[i] = byte(200 + i)
+			}
+			buf := new(bytes.Buffer)
+			buf.Write(expect.Y)
+			buf.Write(expect.Cb)
+			buf.Write(expect.Cr)
+
+			d := &Decoder{}
+			img, err := d.yuvImage(buf, tc.width, tc.height, tc.subsample)
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			if img.CStride != expect.CStride {
+				tt.Errorf("expect CStride %d actual %d", expect.CStride, img.CStride)
+			}
+			if bytes.Equal(img.Cb, expect.Cb) != true || bytes.Equal(img.Cr, expect.Cr) != true {
+				tt.Errorf("expect same chroma planes as image.NewYCbCr")
+			}
+			x, y := tc.width-1, tc.height-1
+			if img.YCbCrAt(x, y) != expect.YCbCrAt(x, y) {
+				tt.Errorf("expect %v actual %v at %d,%d", expect.YCbCrAt(x, y), img.YCbCrAt(x, y), x, y)
+			}
+
+			short := bytes.NewBuffer(buf.Bytes()[:buf.Len()-1])
+			if _, err := d.yuvImage(short, tc.width, tc.height, tc.subsample); err != ErrInvalidPicture {
+				tt.Errorf("expect ErrInvalidPicture actual %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidNAL(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"short length", []byte{1, 0, 0}},
+		{"zero length", []byte{0, 0, 0, 0}},
+		{"zero length with trailing", []byte{0, 0, 0, 0, 0x20}},
+		{"truncated", []byte{8, 0, 0, 0, 0x20, 0x00}},
+		{"max length", []byte{0xff, 0xff, 0xff, 0xff, 0x20}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(tt *testing.T) {
+			// rejected before calling libxvc
+			d := &Decoder{frames: newFrameTable()}
+			if err := d.Decode(tc.data); err != ErrInvalidNAL {
+				tt.Errorf("expect ErrInvalidNAL actual %v", err)
+			}
+			if len(d.frames.frames) != 0 {
+				tt.Errorf("expect no frame entry actual %d", len(d.frames.frames))
+			}
+		})
+	}
+}
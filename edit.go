@@ -0,0 +1,164 @@
+package xvc
+
+import (
+	"io"
+)
+
+// Cut writes the pictures of rs from the access point at or before frame from,
+// to the access point at or after frame to (exclusive) without re-encoding.
+// frame numbers are in decoding order, returns the actual range [start, end) written.
+func Cut(w io.Writer, rs io.ReadSeeker, from, to int64) (int64, int64, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	index, err := BuildIndex(rs)
+	if err != nil {
+		return 0, 0, err
+	}
+	if index.Frames <= from {
+		return 0, 0, ErrFrameOutOfRange
+	}
+	if from < 0 {
+		from = 0
+	}
+	startEntry, ok := index.Lookup(from)
+	if ok != true {
+		return 0, 0, ErrNotAccessPoint
+	}
+
+	end, endOffset := index.Frames, int64(-1)
+	for _, e := range index.Entries {
+		if startEntry.Frame < e.Frame && to <= e.Frame {
+			end, endOffset = e.Frame, e.Offset
+			break
+		}
+	}
+
+	if startEntry.hasSegmentHeader() != true {
+		// insert the SegmentHeader in effect at the access point
+		if _, err := rs.Seek(startEntry.SegmentOffset, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+		nal, err := NewNALReader(rs).ReadNAL()
+		if err != nil {
+			return 0, 0, err
+		}
+		_, err = w.Write(nal.Bytes())
+		nal.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if _, err := rs.Seek(startEntry.Offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	if endOffset < 0 {
+		if _, err := io.Copy(w, rs); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		if _, err := io.CopyN(w, rs, endOffset-startEntry.Offset); err != nil {
+			return 0, 0, err
+		}
+	}
+	return startEntry.Frame, end, nil
+}
+
+// Concat writes streams in order without re-encoding, returns the number of pictures written.
+// each stream must start with IntraAccessPicture, streams with their own SegmentHeader are written as is,
+// so that the parameters are switched at the boundary. a stream without SegmentHeader is
+// considered to have the same parameters as the previous stream, and its SegmentHeader is inserted.
+func Concat(w io.Writer, streams ...io.Reader) (int64, error) {
+	frames := int64(0)
+	segmentHeader := []byte(nil)
+	for _, r := range streams {
+		n, sh, err := concatStream(w, r, segmentHeader)
+		frames += n
+		if err != nil {
+			return frames, err
+		}
+		segmentHeader = sh
+	}
+	return frames, nil
+}
+
+func concatStream(w io.Writer, r io.Reader, segmentHeader []byte) (int64, []byte, error) {
+	reader := NewNALReader(r)
+	frames := int64(0)
+	for {
+		au, err := reader.ReadAccessUnit()
+		if err != nil {
+			if err == io.EOF {
+				return frames, segmentHeader, nil
+			}
+			return frames, segmentHeader, err
+		}
+		for _, nal := range au.NALs {
+			if nal.Type() == SegmentHeader {
+				segmentHeader = append(segmentHeader[:0:0], nal.Bytes()...)
+			}
+		}
+		if frames == 0 {
+			if au.IsKeyframe() != true || segmentHeader == nil {
+				au.Close()
+				return frames, segmentHeader, ErrNotAccessPoint
+			}
+			if au.HasSegmentHeader() != true {
+				if _, err := w.Write(segmentHeader); err != nil {
+					au.Close()
+					return frames, segmentHeader, err
+				}
+			}
+		}
+		if au.Picture() != nil {
+			frames += 1
+		}
+		_, err = w.Write(au.Bytes())
+		au.Close()
+		if err != nil {
+			return frames, segmentHeader, err
+		}
+	}
+}
+
+// Verify decodes all pictures of r, returns the number of decoded pictures.
+func Verify(r io.Reader, funcs ...decoderParameterFunc) (int64, error) {
+	decoder, err := CreateDecoder(funcs...)
+	if err != nil {
+		return 0, err
+	}
+	defer DestroyDecoder(decoder)
+
+	pictures := int64(0)
+	drain := func() {
+		for {
+			pic, err := decoder.DecodedPicture()
+			if err != nil {
+				return
+			}
+			pic.Close()
+			pictures += 1
+		}
+	}
+
+	reader := NewNALReader(r)
+	for {
+		au, err := reader.ReadAccessUnit()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return pictures, err
+		}
+		err = au.Decode(decoder)
+		au.Close()
+		if err != nil {
+			return pictures, err
+		}
+		drain()
+	}
+	decoder.Flush()
+	drain()
+	return pictures, nil
+}
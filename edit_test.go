@@ -0,0 +1,158 @@
+package xvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func testNAL(typ NALUnitType, size int, seed byte) []byte {
+	nal := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(nal[0:4], uint32(size))
+	nal[4] = byte(typ) << 1
+	for i := 5; i < len(nal); i += 1 {
+		nal[i] = seed + byte(i)
+	}
+	return nal
+}
+
+func testNALTypes(t *testing.T, data []byte) []NALUnitType {
+	t.Helper()
+
+	types := make([]NALUnitType, 0, 16)
+	for 0 < len(data) {
+		if len(data) < 5 {
+			t.Fatalf("truncated nal")
+		}
+		size := int(binary.LittleEndian.Uint32(data[0:4]))
+		types = append(types, NALUnitType((data[4]>>1)&0x1f))
+		data = data[4+size:]
+	}
+	return types
+}
+
+func countNAL(types []NALUnitType, typ NALUnitType) int {
+	n := 0
+	for _, t := range types {
+		if t == typ {
+			n += 1
+		}
+	}
+	return n
+}
+
+func TestCut(t *testing.T) {
+	t.Run("segment header in access unit", func(tt *testing.T) {
+		sh := testNAL(SegmentHeader, 16, 0)
+		stream := bytes.Join([][]byte{
+			testNAL(AccessUnitDelimiter, 2, 0), sh, testNAL(IntraAccessPicture, 100, 0),
+			testNAL(PredictedPicture, 50, 1),
+			testNAL(PredictedPicture, 50, 2),
+			testNAL(AccessUnitDelimiter, 2, 0), sh, testNAL(IntraAccessPicture, 100, 3),
+			testNAL(PredictedPicture, 50, 4),
+		}, nil)
+
+		out := bytes.NewBuffer(nil)
+		start, end, err := Cut(out, bytes.NewReader(stream), 3, 5)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if start != 3 || end != 5 {
+			tt.Errorf("expect [3, 5) actual [%d, %d)", start, end)
+		}
+		types := testNALTypes(tt, out.Bytes())
+		if n := countNAL(types, SegmentHeader); n != 1 {
+			tt.Errorf("expect 1 segment header actual %d", n)
+		}
+		if types[0] != AccessUnitDelimiter {
+			tt.Errorf("expect access unit as is actual %s", types[0])
+		}
+	})
+	t.Run("segment header inserted", func(tt *testing.T) {
+		sh := testNAL(SegmentHeader, 16, 0)
+		stream := bytes.Join([][]byte{
+			sh, testNAL(IntraAccessPicture, 100, 0),
+			testNAL(PredictedPicture, 50, 1),
+			testNAL(IntraAccessPicture, 100, 2),
+			testNAL(PredictedPicture, 50, 3),
+		}, nil)
+
+		out := bytes.NewBuffer(nil)
+		start, end, err := Cut(out, bytes.NewReader(stream), 3, 4)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if start != 2 || end != 4 {
+			tt.Errorf("expect [2, 4) actual [%d, %d)", start, end)
+		}
+		types := testNALTypes(tt, out.Bytes())
+		expect := []NALUnitType{SegmentHeader, IntraAccessPicture, PredictedPicture}
+		if len(types) != len(expect) {
+			tt.Fatalf("expect %v actual %v", expect, types)
+		}
+		for i := range expect {
+			if expect[i] != types[i] {
+				tt.Errorf("nal[%d] expect %s actual %s", i, expect[i], types[i])
+			}
+		}
+		if bytes.Equal(out.Bytes()[:len(sh)], sh) != true {
+			tt.Errorf("expect segment header in effect")
+		}
+	})
+}
+
+func TestConcat(t *testing.T) {
+	sh := testNAL(SegmentHeader, 16, 0)
+	a := bytes.Join([][]byte{
+		sh, testNAL(IntraAccessPicture, 100, 0),
+		testNAL(PredictedPicture, 50, 1),
+	}, nil)
+	b := bytes.Join([][]byte{
+		sh, testNAL(IntraAccessPicture, 100, 2),
+		testNAL(PredictedPicture, 50, 3),
+	}, nil)
+	noHeader := bytes.Join([][]byte{
+		testNAL(IntraAccessPicture, 100, 4),
+	}, nil)
+	otherHeader := testNAL(SegmentHeader, 16, 9)
+	other := bytes.Join([][]byte{
+		otherHeader, testNAL(IntraAccessPicture, 100, 5),
+	}, nil)
+
+	t.Run("same segment header", func(tt *testing.T) {
+		out := bytes.NewBuffer(nil)
+		frames, err := Concat(out, bytes.NewReader(a), bytes.NewReader(b), bytes.NewReader(noHeader))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if frames != 5 {
+			tt.Errorf("expect 5 frames actual %d", frames)
+		}
+		types := testNALTypes(tt, out.Bytes())
+		if n := countNAL(types, SegmentHeader); n != 3 {
+			tt.Errorf("expect segment header for each stream actual %d", n)
+		}
+	})
+	t.Run("different segment header", func(tt *testing.T) {
+		out := bytes.NewBuffer(nil)
+		frames, err := Concat(out, bytes.NewReader(a), bytes.NewReader(other), bytes.NewReader(noHeader))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if frames != 4 {
+			tt.Errorf("expect 4 frames actual %d", frames)
+		}
+		// the second header at the join, and inserted again for the stream without header
+		expect := bytes.Join([][]byte{a, other, otherHeader, noHeader}, nil)
+		if bytes.Equal(out.Bytes(), expect) != true {
+			tt.Errorf("expect segment header of the second stream at the join")
+		}
+	})
+	t.Run("not access point", func(tt *testing.T) {
+		out := bytes.NewBuffer(nil)
+		_, err := Concat(out, bytes.NewReader(testNAL(PredictedPicture, 50, 0)))
+		if err != ErrNotAccessPoint {
+			tt.Errorf("expect ErrNotAccessPoint actual %v", err)
+		}
+	})
+}
@@ -2,8 +2,6 @@ package xvc
 
 /*
 #cgo CFLAGS: -I${SRCDIR}/include -I/usr/local/include -I/usr/include
-#cgo darwin LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcenc -lc++
-#cgo linux  LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcenc -lc++
 #include <stdint.h>
 #include <stdlib.h>
 
@@ -26,12 +24,16 @@ type NALUnit struct {
 	size        uint32
 	nalUnitType uint32
 	userData    int64
+	pts         int64
+	dts         int64
 	closed      int32
 	closeFunc   func()
+	leakID      uint64
 }
 
 func (n *NALUnit) Close() {
 	if atomic.CompareAndSwapInt32(&n.closed, 0, 1) {
+		leakTracker.release(n.leakID)
 		n.closeFunc()
 	}
 }
@@ -44,6 +46,16 @@ func (n *NALUnit) UserData() int64 {
 	return n.userData
 }
 
+// PTS returns presentation timestamp in the Timebase of the Encoder
+func (n *NALUnit) PTS() int64 {
+	return n.pts
+}
+
+// DTS returns decoding timestamp in the Timebase of the Encoder
+func (n *NALUnit) DTS() int64 {
+	return n.dts
+}
+
 func (n *NALUnit) Type() NALUnitType {
 	return NALUnitType(n.nalUnitType)
 }
@@ -73,10 +85,90 @@ type encoderParameter struct {
 	bitDepth          uint32
 	internalBitDepath uint32
 	restrictMode      int
+	maxKeypicDistance int // 0: libxvc default
+	timebase          Timebase
+	explicitSettings  ExplicitEncoderSettings
 	bufferPoolFunc    func() BufferPool
+	err               error
+}
+
+// ExplicitEncoderSettings holds encoder tools that are not part of the basic parameters.
+// zero value fields are not applied and keep the libxvc defaults.
+// ranges are checked by libxvc parameters_check (e.g. EncSubGOPLengthTooLarge, EncTooManyRefPics).
+type ExplicitEncoderSettings struct {
+	SubGOPLength int // number of pictures in a sub-GOP
+	NumRefPics   int // number of reference pictures
+	ClosedGOP    bool
+	BetaOffset   int // deblocking beta offset
+	TcOffset     int // deblocking tc offset
+	// Raw is passed through as explicit_encoder_settings of libxvc,
+	// e.g. "fast_mode_selection 1 fast_merge_eval 0"
+	Raw string
+}
+
+// validate rejects negative counts, which setCParam can not distinguish from the libxvc defaults.
+// upper limits are left to libxvc parameters_check.
+func (s ExplicitEncoderSettings) validate() error {
+	if s.SubGOPLength < 0 || s.NumRefPics < 0 {
+		return EncReturnCode(EncInvalidParameter)
+	}
+	return nil
+}
+
+// merge returns s overridden by non-zero fields of o,
+// fields can not be reset by merge, see EncoderExplicitSettingsReset.
+func (s ExplicitEncoderSettings) merge(o ExplicitEncoderSettings) ExplicitEncoderSettings {
+	if o.SubGOPLength != 0 {
+		s.SubGOPLength = o.SubGOPLength
+	}
+	if o.NumRefPics != 0 {
+		s.NumRefPics = o.NumRefPics
+	}
+	if o.ClosedGOP {
+		s.ClosedGOP = true
+	}
+	if o.BetaOffset != 0 {
+		s.BetaOffset = o.BetaOffset
+	}
+	if o.TcOffset != 0 {
+		s.TcOffset = o.TcOffset
+	}
+	if o.Raw != "" {
+		s.Raw = o.Raw
+	}
+	return s
 }
 
-func (e *encoderParameter) setCParam(param *C.xvc_encoder_parameters) {
+func (s ExplicitEncoderSettings) setCParam(param *C.xvc_encoder_parameters) func() {
+	if 0 < s.SubGOPLength {
+		param.sub_gop_length = C.int(s.SubGOPLength)
+	}
+	if 0 < s.NumRefPics {
+		param.num_ref_pics = C.int(s.NumRefPics)
+	}
+	if s.ClosedGOP {
+		param.closed_gop = C.int(1)
+	}
+	if s.BetaOffset != 0 {
+		param.beta_offset = C.int(s.BetaOffset)
+	}
+	if s.TcOffset != 0 {
+		param.tc_offset = C.int(s.TcOffset)
+	}
+
+	if s.Raw == "" {
+		return func() {}
+	}
+	raw := C.CString(s.Raw)
+	param.explicit_encoder_settings = raw
+	return func() {
+		param.explicit_encoder_settings = nil
+		C.free(unsafe.Pointer(raw))
+	}
+}
+
+// setCParam returns a function that releases C memory referenced by param
+func (e *encoderParameter) setCParam(param *C.xvc_encoder_parameters) func() {
 	param.width = C.int(e.width)
 	param.height = C.int(e.height)
 	param.framerate = C.double(e.framerate)
@@ -89,6 +181,9 @@ func (e *encoderParameter) setCParam(param *C.xvc_encoder_parameters) {
 	param.input_bitdepth = C.uint32_t(e.bitDepth)
 	param.internal_bitdepth = C.uint32_t(e.internalBitDepath)
 	param.restricted_mode = C.int(e.restrictMode)
+	if 0 < e.maxKeypicDistance {
+		param.max_keypic_distance = C.int(e.maxKeypicDistance)
+	}
 
 	switch e.chromaFormat {
 	case ChromaFormatMonochrome:
@@ -113,6 +208,14 @@ func (e *encoderParameter) setCParam(param *C.xvc_encoder_parameters) {
 	case ColorMatrixUnified:
 		param.color_matrix = C.XVC_ENC_COLOR_MATRIX_UNDEFINED
 	}
+
+	return e.explicitSettings.setCParam(param)
+}
+
+// reorderDelay returns the DTS offset in ticks for the pictures reordered by libxvc
+func (e *encoderParameter) reorderDelay() int64 {
+	depth := reorderDepth(e.lowDelay, e.explicitSettings.SubGOPLength)
+	return int64(depth) * e.timebase.ticksPerFrame(e.framerate)
 }
 
 func defaultEncoderParameter() *encoderParameter {
@@ -127,6 +230,7 @@ func defaultEncoderParameter() *encoderParameter {
 		bitDepth:          8,
 		internalBitDepath: 8,
 		restrictMode:      3, // baseline
+		timebase:          TimebaseMillisecond,
 		bufferPoolFunc: func() BufferPool {
 			return newSimpleBufferPool(4 * 1024)
 		},
@@ -151,6 +255,88 @@ func EncoderParameterFramerate(rate float32) encoderParameterFunc {
 	}
 }
 
+// EncoderParameterChromaFormat sets the chroma format of the input planes (default 4:2:0).
+// u and v are not read for ChromaFormatMonochrome, but must not be empty.
+// ChromaFormatARGB is not supported, use AlphaEncoder for images with transparency.
+func EncoderParameterChromaFormat(format ChromaFormat) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.chromaFormat = format
+	}
+}
+
+// EncoderParameterInputBitDepth sets the bit depth of the input samples (default 8),
+// samples of 9 bits or more are 16bit little endian and the strides are in bytes.
+func EncoderParameterInputBitDepth(bitDepth int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.bitDepth = uint32(bitDepth)
+	}
+}
+
+func EncoderParameterQP(qp int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.qp = qp
+	}
+}
+
+func EncoderParameterSpeedMode(mode int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.speedMode = mode
+	}
+}
+
+func EncoderParameterTuneMode(mode int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.tuneMode = mode
+	}
+}
+
+func EncoderParameterLowDelay(enable bool) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		if enable {
+			p.lowDelay = 1
+		} else {
+			p.lowDelay = 0
+		}
+	}
+}
+
+func EncoderParameterThreads(threads int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.threads = threads
+	}
+}
+
+// EncoderParameterMaxKeyframeInterval sets the maximum distance in pictures between access pictures.
+func EncoderParameterMaxKeyframeInterval(interval int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.maxKeypicDistance = interval
+	}
+}
+
+// EncoderExplicitSettings merges non-zero fields of settings into the current settings,
+// so that values of EncoderPreset are kept unless they are set explicitly.
+func EncoderExplicitSettings(settings ExplicitEncoderSettings) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.explicitSettings = p.explicitSettings.merge(settings)
+	}
+}
+
+// EncoderExplicitSettingsReset clears the explicit settings set so far (including EncoderPreset) to the libxvc defaults,
+// e.g. to turn ClosedGOP off again.
+func EncoderExplicitSettingsReset() encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.explicitSettings = ExplicitEncoderSettings{}
+	}
+}
+
+// EncoderParameterTimebase sets the unit of PTS/DTS, one tick is num/den seconds.
+// CreateEncoder returns ErrInvalidTimebase unless num and den are positive.
+func EncoderParameterTimebase(num, den int) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		p.timebase = Timebase{num, den}
+	}
+}
+
 func EncoderBufferPool(fn func() BufferPool) encoderParameterFunc {
 	return func(p *encoderParameter) {
 		p.bufferPoolFunc = fn
@@ -161,9 +347,54 @@ type Encoder struct {
 	api     unsafe.Pointer // xvc_encoder_api*
 	encoder unsafe.Pointer // xvc_encoder*
 	pool    BufferPool
+	param   *encoderParameter
+	frames  *frameTable
+	dtsGen  *dtsGenerator
+	nextPTS int64
+	leakID  uint64
 }
 
+type EncodeOptions struct {
+	// ForceKeyframe starts a new segment so that the output of this frame
+	// begins with SegmentHeader and IntraAccessPicture.
+	// pending NALs of the previous segment are flushed and returned first.
+	ForceKeyframe bool
+	// PTS is presentation timestamp of this frame in the Timebase of the Encoder.
+	PTS int64
+}
+
+// Encode encodes a frame with PTS following the previous frame by one frame duration.
 func (e *Encoder) Encode(y, u, v []byte, strideY, strideU, strideV int, userData int64) ([]*NALUnit, error) {
+	return e.EncodeWithOptions(y, u, v, strideY, strideU, strideV, userData, EncodeOptions{
+		PTS: e.nextPTS,
+	})
+}
+
+func (e *Encoder) Timebase() Timebase {
+	return e.param.timebase
+}
+
+func (e *Encoder) EncodeWithOptions(y, u, v []byte, strideY, strideU, strideV int, userData int64, opt EncodeOptions) ([]*NALUnit, error) {
+	if opt.ForceKeyframe != true {
+		return e.encode(y, u, v, strideY, strideU, strideV, userData, opt.PTS)
+	}
+
+	remainingNals, err := e.Reconfigure()
+	if err != nil {
+		return remainingNals, err
+	}
+	nalUnits, err := e.encode(y, u, v, strideY, strideU, strideV, userData, opt.PTS)
+	if err != nil {
+		return remainingNals, err
+	}
+	return append(remainingNals, nalUnits...), nil
+}
+
+func (e *Encoder) encode(y, u, v []byte, strideY, strideU, strideV int, userData int64, pts int64) ([]*NALUnit, error) {
+	seq := e.frames.put(frameInfo{userData, pts})
+	e.dtsGen.push(pts)
+	e.nextPTS = pts + e.param.timebase.ticksPerFrame(e.param.framerate)
+
 	ret := unsafe.Pointer(C.encoder_encode2(
 		(*C.xvc_encoder_api)(e.api),
 		(*C.xvc_encoder)(e.encoder),
@@ -173,9 +404,11 @@ func (e *Encoder) Encode(y, u, v []byte, strideY, strideU, strideV int, userData
 		C.int(strideY),
 		C.int(strideU),
 		C.int(strideV),
-		C.int64_t(userData),
+		C.int64_t(seq),
 	))
 	if ret == nil {
+		e.frames.take(seq)
+		e.dtsGen.remove(pts)
 		return nil, fmt.Errorf("encode2 not succeed")
 	}
 	result := (*C.encode_result_t)(ret)
@@ -202,6 +435,46 @@ func (e *Encoder) Flush() ([]*NALUnit, bool) {
 	return nalUnits, true
 }
 
+// Reconfigure flushes pending output and starts a new segment with parameters
+// updated by funcs (e.g. resolution, framerate or qp).
+// the remaining NALs of the previous segment are returned, and the next Encode emits a new SegmentHeader.
+// the BufferPool is kept, EncoderBufferPool in funcs has no effect.
+// the new encoder is created before the previous one is flushed,
+// if the new parameters are invalid, the encoder continues with the previous parameters.
+func (e *Encoder) Reconfigure(funcs ...encoderParameterFunc) ([]*NALUnit, error) {
+	encParam := *e.param
+	for _, fn := range funcs {
+		fn(&encParam)
+	}
+
+	enc, err := createEncoder(e.api, &encParam)
+	if err != nil {
+		return nil, err
+	}
+
+	nalUnits, _ := e.Flush()
+
+	ret := C.encoder_destroy(
+		(*C.xvc_encoder_api)(e.api),
+		(*C.xvc_encoder)(e.encoder),
+	)
+
+	// previous encoder has been flushed, it can not be continued even if destroy failed
+	e.encoder = enc
+	e.param = &encParam
+	e.frames.reset()
+	e.dtsGen.reset(encParam.reorderDelay())
+	if ret != C.XVC_ENC_OK {
+		return nalUnits, EncReturnCode(ret)
+	}
+	return nalUnits, nil
+}
+
+// encodeResultCount returns the number of encode_result_t not freed by free_encode_result
+func encodeResultCount() int64 {
+	return int64(C.encode_result_count())
+}
+
 func (e *Encoder) copyNALUnits(result *C.encode_result_t) ([]*NALUnit, error) {
 	numNals := int(result.num_of_nals)
 	nals := []C.encode_nal_unit_buf_t{}
@@ -236,17 +509,57 @@ func (e *Encoder) copyNALUnits(result *C.encode_result_t) ([]*NALUnit, error) {
 			buffer:      buf,
 			size:        bufSize,
 			nalUnitType: uint32(nals[i].nal_unit_type),
-			userData:    int64(nals[i].user_data),
+			userData:    int64(nals[i].user_data), // sequence number, replaced by setTimestamps
 			closed:      int32(0),
 			closeFunc: func() {
 				buf.Reset()
 				e.pool.Put(buf)
 			},
+			leakID: leakTracker.track(AllocNALUnit),
 		}
 	}
+	e.setTimestamps(nalUnits)
 	return nalUnits, nil
 }
 
+// setTimestamps restores user data and PTS of each picture from the sequence number passed to libxvc,
+// and assigns DTS in output (decoding) order.
+// non-picture NALs (SegmentHeader, Sei, ...) take the values of the following picture.
+func (e *Encoder) setTimestamps(nalUnits []*NALUnit) {
+	var last *NALUnit
+	for _, n := range nalUnits {
+		if n.Type().IsPicture() != true {
+			continue
+		}
+		info, ok := e.frames.take(n.userData)
+		if ok != true {
+			continue
+		}
+		n.userData = info.userData
+		n.pts = info.pts
+		n.dts = e.dtsGen.pop(info.pts)
+		last = n
+	}
+
+	next := last
+	for i := len(nalUnits) - 1; 0 <= i; i -= 1 {
+		n := nalUnits[i]
+		if n.Type().IsPicture() {
+			next = n
+			continue
+		}
+		if next == nil {
+			n.userData, n.pts, n.dts = 0, 0, 0
+			continue
+		}
+		n.userData, n.pts, n.dts = next.userData, next.pts, next.dts
+	}
+}
+
+func encoderAvailable() bool {
+	return C.encoder_api_get() != nil
+}
+
 func CreateEncoder(funcs ...encoderParameterFunc) (*Encoder, error) {
 	encParam := defaultEncoderParameter()
 	for _, fn := range funcs {
@@ -254,6 +567,43 @@ func CreateEncoder(funcs ...encoderParameterFunc) (*Encoder, error) {
 	}
 
 	api := unsafe.Pointer(C.encoder_api_get())
+	if api == nil {
+		return nil, ErrNotAvailable
+	}
+
+	enc, err := createEncoder(api, encParam)
+	if err != nil {
+		return nil, err
+	}
+	encoder := &Encoder{
+		api:     api,
+		encoder: enc,
+		pool:    encParam.bufferPoolFunc(),
+		param:   encParam,
+		frames:  newFrameTable(),
+		dtsGen:  newDTSGenerator(encParam.reorderDelay()),
+		nextPTS: 0,
+		leakID:  leakTracker.track(AllocEncoder),
+	}
+	runtime.SetFinalizer(encoder, finalizeEncoder)
+	return encoder, nil
+}
+
+func createEncoder(api unsafe.Pointer, encParam *encoderParameter) (unsafe.Pointer, error) {
+	if encParam.err != nil {
+		return nil, encParam.err
+	}
+	if err := encParam.explicitSettings.validate(); err != nil {
+		return nil, err
+	}
+	if encParam.timebase.Valid() != true {
+		return nil, ErrInvalidTimebase
+	}
+	if encParam.chromaFormat == ChromaFormatARGB {
+		// 4 planes input is not supported, AlphaEncoder encodes alpha as an auxiliary stream
+		return nil, EncReturnCode(EncUnsupportedChromaFormat)
+	}
+
 	param := unsafe.Pointer(C.encoder_parameters_create(
 		(*C.xvc_encoder_api)(api),
 	))
@@ -271,7 +621,8 @@ func CreateEncoder(funcs ...encoderParameterFunc) (*Encoder, error) {
 		return nil, EncReturnCode(ret)
 	}
 
-	encParam.setCParam((*C.xvc_encoder_parameters)(param))
+	free := encParam.setCParam((*C.xvc_encoder_parameters)(param))
+	defer free()
 
 	if ret := C.encoder_parameters_check(
 		(*C.xvc_encoder_api)(api),
@@ -284,9 +635,10 @@ func CreateEncoder(funcs ...encoderParameterFunc) (*Encoder, error) {
 		(*C.xvc_encoder_api)(api),
 		(*C.xvc_encoder_parameters)(param),
 	))
-	encoder := &Encoder{api, enc, encParam.bufferPoolFunc()}
-	runtime.SetFinalizer(encoder, finalizeEncoder)
-	return encoder, nil
+	if enc == nil {
+		return nil, ErrCreateEncoder
+	}
+	return enc, nil
 }
 
 func finalizeEncoder(encoder *Encoder) {
@@ -295,6 +647,7 @@ func finalizeEncoder(encoder *Encoder) {
 
 func DestroyEncoder(encoder *Encoder) error {
 	runtime.SetFinalizer(encoder, nil) // clear finalizer
+	leakTracker.release(encoder.leakID)
 
 	if ret := C.encoder_destroy(
 		(*C.xvc_encoder_api)(encoder.api),
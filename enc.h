@@ -18,7 +18,7 @@ typedef struct encode_result_t {
   int num_of_nals;
 } encode_result_t;
 
-void free_encode_result(encode_result_t* result) {
+static void release_encode_result(encode_result_t* result) {
   if(result != NULL) {
     if(result->nals != NULL) {
       for(int i = 0; i < result->num_of_nals; i += 1) {
@@ -33,10 +33,24 @@ void free_encode_result(encode_result_t* result) {
   free(result);
 }
 
+// number of encode_result_t not freed yet, read by leak detection
+static int64_t go_xvc_encode_result_count = 0;
+
+int64_t encode_result_count() {
+  return __atomic_load_n(&go_xvc_encode_result_count, __ATOMIC_SEQ_CST);
+}
+
+void free_encode_result(encode_result_t* result) {
+  if(result != NULL) {
+    __atomic_sub_fetch(&go_xvc_encode_result_count, 1, __ATOMIC_SEQ_CST);
+  }
+  release_encode_result(result);
+}
+
 encode_result_t* create_encode_result(xvc_enc_nal_unit *nal_units, int num_nal_units) {
   encode_result_t* result = (encode_result_t *) malloc(sizeof(encode_result_t));
   if(result == NULL) {
-    free_encode_result(result);
+    release_encode_result(result);
     return NULL;
   }
   memset(result, 0, sizeof(encode_result_t));
@@ -45,7 +59,7 @@ encode_result_t* create_encode_result(xvc_enc_nal_unit *nal_units, int num_nal_u
 
   result->nals = (encode_nal_unit_buf_t *) malloc(num_nal_units * sizeof(encode_nal_unit_buf_t));
   if(result->nals == NULL) {
-    free_encode_result(result);
+    release_encode_result(result);
     return NULL;
   }
   memset(result->nals, 0, num_nal_units * sizeof(encode_nal_unit_buf_t));
@@ -53,7 +67,7 @@ encode_result_t* create_encode_result(xvc_enc_nal_unit *nal_units, int num_nal_u
   for(int i = 0; i < num_nal_units; i += 1) {
     result->nals[i].buf = (unsigned char *) malloc(nal_units[i].size);
     if(result->nals[i].buf == NULL) {
-      free_encode_result(result);
+      release_encode_result(result);
       return NULL;
     }
     result->nals[i].nal_size[0] = (nal_units[i].size) & 0xFF;
@@ -67,12 +81,44 @@ encode_result_t* create_encode_result(xvc_enc_nal_unit *nal_units, int num_nal_u
   }
   result->num_of_nals = num_nal_units;
 
+  __atomic_add_fetch(&go_xvc_encode_result_count, 1, __ATOMIC_SEQ_CST);
   return result;
 }
 
+#ifdef GO_XVC_DLOPEN
+#include <dlfcn.h>
+#include <pthread.h>
+
+#ifdef __APPLE__
+#define GO_XVC_ENC_LIBNAME "libxvcenc.dylib"
+#else
+#define GO_XVC_ENC_LIBNAME "libxvcenc.so"
+#endif
+
+// dlopen once, encoder_api_get is called concurrently by CreateEncoder
+static void *go_xvc_enc_handle = NULL;
+static pthread_once_t go_xvc_enc_once = PTHREAD_ONCE_INIT;
+
+static void go_xvc_enc_open(void) {
+  go_xvc_enc_handle = dlopen(GO_XVC_ENC_LIBNAME, RTLD_NOW | RTLD_GLOBAL);
+}
+
+const xvc_encoder_api* encoder_api_get() {
+  pthread_once(&go_xvc_enc_once, go_xvc_enc_open);
+  if(go_xvc_enc_handle == NULL) {
+    return NULL;
+  }
+  const xvc_encoder_api* (*api_get)(void) = (const xvc_encoder_api* (*)(void)) dlsym(go_xvc_enc_handle, "xvc_encoder_api_get");
+  if(api_get == NULL) {
+    return NULL;
+  }
+  return api_get();
+}
+#else
 const xvc_encoder_api* encoder_api_get() {
   return xvc_encoder_api_get();
 }
+#endif
 
 xvc_encoder_parameters* encoder_parameters_create(xvc_encoder_api* api) {
   return (api->parameters_create)();
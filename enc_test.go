@@ -0,0 +1,79 @@
+package xvc
+
+import (
+	"testing"
+)
+
+func applyEncoderParameter(funcs ...encoderParameterFunc) *encoderParameter {
+	p := defaultEncoderParameter()
+	for _, fn := range funcs {
+		fn(p)
+	}
+	return p
+}
+
+func TestExplicitEncoderSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings ExplicitEncoderSettings
+		expect   error
+	}{
+		{"zero", ExplicitEncoderSettings{}, nil},
+		{"positive", ExplicitEncoderSettings{SubGOPLength: 8, NumRefPics: 2, BetaOffset: -3, TcOffset: 3}, nil},
+		{"negative sub gop", ExplicitEncoderSettings{SubGOPLength: -1}, EncReturnCode(EncInvalidParameter)},
+		{"negative ref pics", ExplicitEncoderSettings{NumRefPics: -1}, EncReturnCode(EncInvalidParameter)},
+	}
+	for _, tc := range tests {
+		if err := tc.settings.validate(); err != tc.expect {
+			t.Errorf("%s: expect %v actual %v", tc.name, tc.expect, err)
+		}
+	}
+}
+
+func TestEncoderExplicitSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		funcs  []encoderParameterFunc
+		expect ExplicitEncoderSettings
+	}{
+		{
+			name: "merge non-zero fields",
+			funcs: []encoderParameterFunc{
+				EncoderExplicitSettings(ExplicitEncoderSettings{SubGOPLength: 8, ClosedGOP: true, Raw: "a 1"}),
+				EncoderExplicitSettings(ExplicitEncoderSettings{NumRefPics: 2, BetaOffset: -2}),
+			},
+			expect: ExplicitEncoderSettings{SubGOPLength: 8, NumRefPics: 2, ClosedGOP: true, BetaOffset: -2, Raw: "a 1"},
+		},
+		{
+			name: "preset kept",
+			funcs: []encoderParameterFunc{
+				EncoderPreset(PresetVODHighQuality),
+				EncoderExplicitSettings(ExplicitEncoderSettings{NumRefPics: 3}),
+			},
+			expect: ExplicitEncoderSettings{SubGOPLength: encoderPresets[PresetVODHighQuality].subGOPLength, NumRefPics: 3},
+		},
+		{
+			name: "zero does not reset",
+			funcs: []encoderParameterFunc{
+				EncoderExplicitSettings(ExplicitEncoderSettings{SubGOPLength: 8, ClosedGOP: true}),
+				EncoderExplicitSettings(ExplicitEncoderSettings{}),
+			},
+			expect: ExplicitEncoderSettings{SubGOPLength: 8, ClosedGOP: true},
+		},
+		{
+			name: "reset",
+			funcs: []encoderParameterFunc{
+				EncoderPreset(PresetVODHighQuality),
+				EncoderExplicitSettings(ExplicitEncoderSettings{ClosedGOP: true, TcOffset: 1}),
+				EncoderExplicitSettingsReset(),
+				EncoderExplicitSettings(ExplicitEncoderSettings{NumRefPics: 1}),
+			},
+			expect: ExplicitEncoderSettings{NumRefPics: 1},
+		},
+	}
+	for _, tc := range tests {
+		if actual := applyEncoderParameter(tc.funcs...).explicitSettings; actual != tc.expect {
+			t.Errorf("%s: expect %+v actual %+v", tc.name, tc.expect, actual)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package xvc
+
+import (
+	"image"
+	"image/color"
+)
+
+// toYCbCr420 returns img as 4:2:0 YCbCr planes for Encoder, *image.YCbCr of 4:2:0 is returned as is.
+func toYCbCr420(img image.Image) *image.YCbCr {
+	if ycc, ok := img.(*image.YCbCr); ok && ycc.SubsampleRatio == image.YCbCrSubsampleRatio420 && ycc.Rect.Min == (image.Point{}) {
+		return ycc
+	}
+
+	b := img.Bounds()
+	dst := image.NewYCbCr(image.Rect(0, 0, b.Dx(), b.Dy()), image.YCbCrSubsampleRatio420)
+	for y := 0; y < b.Dy(); y += 1 {
+		for x := 0; x < b.Dx(); x += 1 {
+			c := color.YCbCrModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.YCbCr)
+			dst.Y[dst.YOffset(x, y)] = c.Y
+			// top-left sample of 2x2 block
+			if (x&1) == 0 && (y&1) == 0 {
+				dst.Cb[dst.COffset(x, y)] = c.Cb
+				dst.Cr[dst.COffset(x, y)] = c.Cr
+			}
+		}
+	}
+	return dst
+}
+
+// cloneYCbCr copies img into memory owned by the caller, e.g. Image() of DecodedPicture before Close.
+func cloneYCbCr(img *image.YCbCr) *image.YCbCr {
+	dst := &image.YCbCr{
+		Y:              make([]byte, len(img.Y)),
+		Cb:             make([]byte, len(img.Cb)),
+		Cr:             make([]byte, len(img.Cr)),
+		YStride:        img.YStride,
+		CStride:        img.CStride,
+		SubsampleRatio: img.SubsampleRatio,
+		Rect:           img.Rect,
+	}
+	copy(dst.Y, img.Y)
+	copy(dst.Cb, img.Cb)
+	copy(dst.Cr, img.Cr)
+	return dst
+}
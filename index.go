@@ -0,0 +1,275 @@
+package xvc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	indexMagic   string = "XVCI"
+	indexVersion uint8  = 1
+)
+
+// IndexEntry is a random access point of the stream
+type IndexEntry struct {
+	// Frame is the number of pictures preceding the access point, in decoding order
+	Frame int64
+	// Offset is the byte offset of the AccessUnit of IntraAccessPicture
+	Offset int64
+	// SegmentOffset is the byte offset of the SegmentHeader in effect at the access point
+	SegmentOffset int64
+}
+
+// hasSegmentHeader returns whether the AccessUnit at Offset contains the SegmentHeader in effect
+func (e IndexEntry) hasSegmentHeader() bool {
+	return e.Offset <= e.SegmentOffset
+}
+
+// Index is the random access points of a length prefixed NAL stream (.xvc file)
+type Index struct {
+	Frames  int64
+	Entries []IndexEntry
+}
+
+// Lookup returns the last access point at or before frame
+func (idx *Index) Lookup(frame int64) (IndexEntry, bool) {
+	if frame < 0 || idx.Frames <= frame {
+		return IndexEntry{}, false
+	}
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return frame < idx.Entries[i].Frame
+	})
+	if i < 1 {
+		return IndexEntry{}, false
+	}
+	return idx.Entries[i-1], true
+}
+
+// WriteTo serializes Index, e.g. as a sidecar file of the stream
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, 4+1+8+4+(len(idx.Entries)*24))
+	buf = append(buf, indexMagic...)
+	buf = append(buf, indexVersion)
+	buf = appendUint64(buf, uint64(idx.Frames))
+	buf = appendUint32(buf, uint32(len(idx.Entries)))
+	for _, e := range idx.Entries {
+		buf = appendUint64(buf, uint64(e.Frame))
+		buf = appendUint64(buf, uint64(e.Offset))
+		buf = appendUint64(buf, uint64(e.SegmentOffset))
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadIndex deserializes Index written by Index.WriteTo
+func ReadIndex(r io.Reader) (*Index, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 17 || string(data[0:4]) != indexMagic || data[4] != indexVersion {
+		return nil, ErrInvalidIndex
+	}
+	frames := int64(binary.LittleEndian.Uint64(data[5:13]))
+	count := int(binary.LittleEndian.Uint32(data[13:17]))
+	data = data[17:]
+	if len(data) != count*24 {
+		return nil, ErrInvalidIndex
+	}
+	entries := make([]IndexEntry, count)
+	for i := 0; i < count; i += 1 {
+		b := data[i*24:]
+		entries[i] = IndexEntry{
+			Frame:         int64(binary.LittleEndian.Uint64(b[0:8])),
+			Offset:        int64(binary.LittleEndian.Uint64(b[8:16])),
+			SegmentOffset: int64(binary.LittleEndian.Uint64(b[16:24])),
+		}
+	}
+	return &Index{Frames: frames, Entries: entries}, nil
+}
+
+// BuildIndex scans length prefixed NAL units and records IntraAccessPicture positions.
+// only NAL headers are read, NAL payloads are skipped.
+func BuildIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	idx := &Index{
+		Frames:  0,
+		Entries: make([]IndexEntry, 0, 64),
+	}
+
+	offset := int64(0)
+	unitOffset := int64(0)
+	segmentOffset := int64(-1)
+	prevPicture := true
+	header := [5]byte{}
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if err == io.EOF {
+				return idx, nil
+			}
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(header[0:4]))
+		if size < 1 {
+			return nil, ErrInvalidNAL
+		}
+		if _, err := io.CopyN(io.Discard, br, size-1); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if prevPicture {
+			unitOffset = offset // first NAL of AccessUnit
+		}
+		nalType := xvctype.ParseNALUnitType(header[4])
+		if nalType == SegmentHeader {
+			segmentOffset = offset
+		}
+		if nalType.IsPicture() {
+			if nalType == IntraAccessPicture && 0 <= segmentOffset {
+				idx.Entries = append(idx.Entries, IndexEntry{
+					Frame:         idx.Frames,
+					Offset:        unitOffset,
+					SegmentOffset: segmentOffset,
+				})
+			}
+			idx.Frames += 1
+		}
+		prevPicture = nalType.IsPicture()
+		offset += 4 + size
+	}
+}
+
+// SeekReader decodes pictures of the stream with random access by Index.
+// UserData of the decoded pictures is the frame number in decoding order.
+type SeekReader struct {
+	rs      io.ReadSeeker
+	index   *Index
+	decoder *Decoder
+	reader  *NALReader
+	frame   int64
+	flushed bool
+}
+
+func NewSeekReader(rs io.ReadSeeker, index *Index, decoder *Decoder) *SeekReader {
+	return &SeekReader{
+		rs:      rs,
+		index:   index,
+		decoder: decoder,
+		reader:  NewNALReader(rs),
+		frame:   0,
+		flushed: false,
+	}
+}
+
+// SeekFrame positions the stream at the access point preceding frame (in decoding order),
+// and decodes forward to return the picture of frame.
+// pictures are reordered by the decoder, so the pictures output before frame are discarded by their UserData.
+// subsequent NextPicture returns the pictures following frame in output order.
+func (s *SeekReader) SeekFrame(frame int64) (*DecodedPicture, error) {
+	entry, ok := s.index.Lookup(frame)
+	if ok != true {
+		return nil, ErrFrameOutOfRange
+	}
+
+	// discard pictures of the previous position
+	if s.decoder.Flush() != true {
+		return nil, ErrFlushFailed
+	}
+	for {
+		pic, err := s.decoder.DecodedPicture()
+		if err != nil {
+			if err == DecReturnCode(DecNoDecodedPic) {
+				break
+			}
+			return nil, err
+		}
+		pic.Close()
+	}
+
+	if entry.hasSegmentHeader() != true {
+		if _, err := s.rs.Seek(entry.SegmentOffset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		nal, err := NewNALReader(s.rs).ReadNAL()
+		if err != nil {
+			return nil, err
+		}
+		err = s.decoder.DecodeNALUnit(nal)
+		nal.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.rs.Seek(entry.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	s.reader = NewNALReader(s.rs)
+	s.frame = entry.Frame
+	s.flushed = false
+
+	for {
+		pic, err := s.NextPicture()
+		if err != nil {
+			if err == io.EOF {
+				return nil, ErrFrameOutOfRange
+			}
+			return nil, err
+		}
+		if pic.UserData() == frame {
+			return pic, nil
+		}
+		pic.Close()
+	}
+}
+
+// NextPicture decodes forward and returns the next picture, io.EOF at the end of stream.
+func (s *SeekReader) NextPicture() (*DecodedPicture, error) {
+	for {
+		pic, err := s.decoder.DecodedPicture()
+		if err == nil {
+			return pic, nil
+		}
+		if err != DecReturnCode(DecNoDecodedPic) {
+			return nil, err
+		}
+		if s.flushed {
+			return nil, io.EOF
+		}
+
+		au, err := s.reader.ReadAccessUnit()
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			if s.decoder.Flush() != true {
+				return nil, ErrFlushFailed
+			}
+			s.flushed = true
+			continue
+		}
+		if pic := au.Picture(); pic != nil {
+			pic.userData = s.frame
+			s.frame += 1
+		}
+		err = au.Decode(s.decoder)
+		au.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
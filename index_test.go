@@ -0,0 +1,133 @@
+package xvc
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("_example/testdata/" + name)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return data
+}
+
+func TestIndex(t *testing.T) {
+	sh := readTestdata(t, "nal_0_16.xvc")
+	iap := readTestdata(t, "nal_1_1.xvc")
+	// SH IAP | IAP (segment header of the first access unit in effect) | SH IAP
+	stream := bytes.Join([][]byte{sh, iap, iap, sh, iap}, nil)
+
+	idx, err := BuildIndex(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := []IndexEntry{
+		{Frame: 0, Offset: 0, SegmentOffset: 0},
+		{Frame: 1, Offset: int64(len(sh) + len(iap)), SegmentOffset: 0},
+		{Frame: 2, Offset: int64(len(sh) + 2*len(iap)), SegmentOffset: int64(len(sh) + 2*len(iap))},
+	}
+
+	t.Run("BuildIndex", func(tt *testing.T) {
+		if idx.Frames != 3 {
+			tt.Errorf("expect 3 frames actual %d", idx.Frames)
+		}
+		if len(idx.Entries) != len(expect) {
+			tt.Fatalf("expect %d entries actual %v", len(expect), idx.Entries)
+		}
+		for i, e := range expect {
+			if idx.Entries[i] != e {
+				tt.Errorf("entry[%d]: expect %+v actual %+v", i, e, idx.Entries[i])
+			}
+		}
+		if idx.Entries[1].hasSegmentHeader() {
+			tt.Errorf("expect segment header outside of the access unit")
+		}
+		if idx.Entries[2].hasSegmentHeader() != true {
+			tt.Errorf("expect segment header in the access unit")
+		}
+	})
+	t.Run("Lookup", func(tt *testing.T) {
+		tests := []struct {
+			frame int64
+			ok    bool
+			entry int
+		}{
+			{-1, false, 0},
+			{0, true, 0},
+			{1, true, 1},
+			{2, true, 2},
+			{3, false, 0},
+		}
+		for _, tc := range tests {
+			e, ok := idx.Lookup(tc.frame)
+			if ok != tc.ok {
+				tt.Errorf("frame %d: expect ok=%v actual %v", tc.frame, tc.ok, ok)
+				continue
+			}
+			if ok && e != expect[tc.entry] {
+				tt.Errorf("frame %d: expect %+v actual %+v", tc.frame, expect[tc.entry], e)
+			}
+		}
+	})
+	t.Run("serialize", func(tt *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		n, err := idx.WriteTo(buf)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if n != int64(buf.Len()) {
+			tt.Errorf("expect %d bytes written actual %d", buf.Len(), n)
+		}
+
+		data := buf.Bytes()
+		read, err := ReadIndex(bytes.NewReader(data))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if read.Frames != idx.Frames || len(read.Entries) != len(idx.Entries) {
+			tt.Fatalf("expect %+v actual %+v", idx, read)
+		}
+		for i := range idx.Entries {
+			if read.Entries[i] != idx.Entries[i] {
+				tt.Errorf("entry[%d]: expect %+v actual %+v", i, idx.Entries[i], read.Entries[i])
+			}
+		}
+
+		badMagic := append([]byte("XVCX"), data[4:]...)
+		for name, b := range map[string][]byte{
+			"magic":     badMagic,
+			"truncated": data[:len(data)-1],
+			"header":    data[:16],
+		} {
+			if _, err := ReadIndex(bytes.NewReader(b)); err != ErrInvalidIndex {
+				tt.Errorf("%s: expect ErrInvalidIndex actual %v", name, err)
+			}
+		}
+	})
+}
+
+func TestBuildIndexInvalid(t *testing.T) {
+	iap := readTestdata(t, "nal_1_1.xvc")
+
+	if _, err := BuildIndex(bytes.NewReader(iap[:len(iap)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("expect io.ErrUnexpectedEOF actual %v", err)
+	}
+	zero := append([]byte{0, 0, 0, 0, 0}, iap...)
+	if _, err := BuildIndex(bytes.NewReader(zero)); err != ErrInvalidNAL {
+		t.Errorf("expect ErrInvalidNAL actual %v", err)
+	}
+	// access point without segment header is not recorded
+	idx, err := BuildIndex(bytes.NewReader(iap))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if idx.Frames != 1 || len(idx.Entries) != 0 {
+		t.Errorf("expect 1 frame without entries actual %+v", idx)
+	}
+}
@@ -0,0 +1,219 @@
+package xvc
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// AllocKind is the kind of allocation counted by leak detection
+type AllocKind uint8
+
+const (
+	AllocEncoder        AllocKind = iota // C: encoder_create, released by DestroyEncoder
+	AllocDecoder                         // C: decoder_create, released by DestroyDecoder
+	AllocNALUnit                         // released by NALUnit.Close
+	AllocDecodedPicture                  // released by DecodedPicture.Close
+	AllocEncodeResult                    // C: create_encode_result, released by free_encode_result
+	AllocDecoderPicture                  // C: decoder_picture_create, released by decoder_picture_destroy
+)
+
+func (k AllocKind) String() string {
+	switch k {
+	case AllocEncoder:
+		return "encoder"
+	case AllocDecoder:
+		return "decoder"
+	case AllocNALUnit:
+		return "NALUnit"
+	case AllocDecodedPicture:
+		return "DecodedPicture"
+	case AllocEncodeResult:
+		return "encode_result"
+	case AllocDecoderPicture:
+		return "decoder_picture"
+	}
+	return "unknown"
+}
+
+// Allocation is an outstanding allocation with the stack trace of its creation
+type Allocation struct {
+	ID    uint64
+	Kind  AllocKind
+	Stack string
+}
+
+func (a Allocation) String() string {
+	return fmt.Sprintf("%s #%d allocated at\n%s", a.Kind, a.ID, a.Stack)
+}
+
+// C allocations freed inside the package are counted by the C wrappers (enc.h, dec.h) regardless of
+// leak detection, they have no stack trace.
+var cAllocCounters = map[AllocKind]func() int64{
+	AllocEncodeResult:   encodeResultCount,
+	AllocDecoderPicture: decoderPictureCount,
+}
+
+var cAllocKinds = []AllocKind{AllocEncodeResult, AllocDecoderPicture}
+
+func cAllocCounts() map[AllocKind]int64 {
+	counts := make(map[AllocKind]int64, len(cAllocKinds))
+	for _, kind := range cAllocKinds {
+		counts[kind] = cAllocCounters[kind]()
+	}
+	return counts
+}
+
+// leak detection is enabled by EnableLeakDetection or environment variable GO_XVC_LEAK_DETECTION=1
+var leakTracker = newAllocTracker(os.Getenv("GO_XVC_LEAK_DETECTION") == "1")
+
+type allocTracker struct {
+	enabled int32
+	mutex   *sync.Mutex
+	seq     uint64
+	allocs  map[uint64]Allocation
+}
+
+func newAllocTracker(enabled bool) *allocTracker {
+	t := &allocTracker{
+		enabled: 0,
+		mutex:   new(sync.Mutex),
+		seq:     0,
+		allocs:  make(map[uint64]Allocation),
+	}
+	if enabled {
+		t.enabled = 1
+	}
+	return t
+}
+
+func (t *allocTracker) isEnabled() bool {
+	return atomic.LoadInt32(&t.enabled) == 1
+}
+
+// track returns the id of the allocation, 0 if disabled
+func (t *allocTracker) track(kind AllocKind) uint64 {
+	if t.isEnabled() != true {
+		return 0
+	}
+	stack := callerStack(3)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.seq += 1
+	t.allocs[t.seq] = Allocation{ID: t.seq, Kind: kind, Stack: stack}
+	return t.seq
+}
+
+func (t *allocTracker) release(id uint64) {
+	if id == 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.allocs, id)
+}
+
+func (t *allocTracker) lastID() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.seq
+}
+
+func (t *allocTracker) outstanding(after uint64) []Allocation {
+	t.mutex.Lock()
+	allocs := make([]Allocation, 0, len(t.allocs))
+	for _, a := range t.allocs {
+		if after < a.ID {
+			allocs = append(allocs, a)
+		}
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(allocs, func(i, j int) bool {
+		return allocs[i].ID < allocs[j].ID
+	})
+	return allocs
+}
+
+func callerStack(skip int) string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(skip, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	sb := new(strings.Builder)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(sb, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+		if more != true {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// EnableLeakDetection enables or disables tracking of encoder/decoder handles and unreleased NALUnit/DecodedPicture.
+// allocations made while disabled are not counted.
+func EnableLeakDetection(enable bool) {
+	if enable {
+		atomic.StoreInt32(&leakTracker.enabled, 1)
+	} else {
+		atomic.StoreInt32(&leakTracker.enabled, 0)
+	}
+}
+
+func LeakDetectionEnabled() bool {
+	return leakTracker.isEnabled()
+}
+
+// OutstandingAllocations returns the allocations not released yet, in order of creation.
+func OutstandingAllocations() []Allocation {
+	return leakTracker.outstanding(0)
+}
+
+// OutstandingCAllocations returns the number of encode_result / decoder_picture not freed yet by kind.
+func OutstandingCAllocations() map[AllocKind]int64 {
+	return cAllocCounts()
+}
+
+// LeakTB is the subset of testing.TB used by CheckLeaks
+type LeakTB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// CheckLeaks enables leak detection during the test, and fails the test
+// if allocations made by the test are outstanding at the end.
+// tests running in parallel with the test are counted as well.
+//
+//	func TestEncode(t *testing.T) {
+//		xvc.CheckLeaks(t)
+//		...
+//	}
+func CheckLeaks(t LeakTB) {
+	t.Helper()
+
+	enabled := LeakDetectionEnabled()
+	EnableLeakDetection(true)
+	start := leakTracker.lastID()
+	startC := cAllocCounts()
+	t.Cleanup(func() {
+		t.Helper()
+		for _, a := range leakTracker.outstanding(start) {
+			t.Errorf("xvc: leaked %s", a)
+		}
+		for _, kind := range cAllocKinds {
+			if n := cAllocCounters[kind]() - startC[kind]; 0 < n {
+				t.Errorf("xvc: leaked %d %s", n, kind)
+			}
+		}
+		EnableLeakDetection(enabled)
+	})
+}
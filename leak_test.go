@@ -0,0 +1,107 @@
+package xvc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeTB records failures of CheckLeaks instead of failing the test
+type fakeTB struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeTB) finish() {
+	for i := len(f.cleanups) - 1; 0 <= i; i -= 1 {
+		f.cleanups[i]()
+	}
+}
+
+func readTestNAL(t *testing.T) *NALUnit {
+	t.Helper()
+
+	nal, err := NewNALReader(bytes.NewReader(testNAL(IntraAccessPicture, 100, 0))).ReadNAL()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return nal
+}
+
+func TestCheckLeaks(t *testing.T) {
+	enabled := LeakDetectionEnabled()
+	t.Cleanup(func() {
+		EnableLeakDetection(enabled)
+	})
+
+	t.Run("leaked", func(tt *testing.T) {
+		f := &fakeTB{}
+		CheckLeaks(f)
+		nal := readTestNAL(tt)
+		defer nal.Close()
+
+		f.finish()
+		if len(f.errors) != 1 {
+			tt.Fatalf("expect 1 leak actual %d: %v", len(f.errors), f.errors)
+		}
+		if strings.Contains(f.errors[0], AllocNALUnit.String()) != true {
+			tt.Errorf("expect NALUnit leak actual %s", f.errors[0])
+		}
+		if strings.Contains(f.errors[0], "readTestNAL") != true {
+			tt.Errorf("expect stack trace of allocation actual %s", f.errors[0])
+		}
+	})
+	t.Run("closed", func(tt *testing.T) {
+		f := &fakeTB{}
+		CheckLeaks(f)
+		nal := readTestNAL(tt)
+		nal.Close()
+
+		f.finish()
+		if len(f.errors) != 0 {
+			tt.Errorf("expect no leak actual %v", f.errors)
+		}
+	})
+	t.Run("C allocation", func(tt *testing.T) {
+		counter := cAllocCounters[AllocEncodeResult]
+		defer func() {
+			cAllocCounters[AllocEncodeResult] = counter
+		}()
+		count := int64(3)
+		cAllocCounters[AllocEncodeResult] = func() int64 {
+			return count
+		}
+
+		f := &fakeTB{}
+		CheckLeaks(f)
+		count += 1 // create_encode_result without free_encode_result
+
+		f.finish()
+		if len(f.errors) != 1 {
+			tt.Fatalf("expect 1 leak actual %d: %v", len(f.errors), f.errors)
+		}
+		if strings.Contains(f.errors[0], "1 "+AllocEncodeResult.String()) != true {
+			tt.Errorf("expect encode_result leak actual %s", f.errors[0])
+		}
+	})
+	t.Run("disabled", func(tt *testing.T) {
+		EnableLeakDetection(false)
+		nal := readTestNAL(tt)
+		defer nal.Close()
+
+		if nal.leakID != 0 {
+			tt.Errorf("expect not tracked while disabled actual id %d", nal.leakID)
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !xvc_dlopen && !xvc_static
+// +build !xvc_dlopen,!xvc_static
+
+package xvc
+
+/*
+#cgo darwin LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcenc -lxvcdec -lc++
+#cgo linux  LDFLAGS: -L${SRCDIR} -L/usr/local/lib -L/usr/lib -lxvcenc -lxvcdec -lc++
+*/
+import "C"
@@ -0,0 +1,14 @@
+//go:build xvc_dlopen && !xvc_static
+// +build xvc_dlopen,!xvc_static
+
+package xvc
+
+// libxvcenc / libxvcdec are loaded by dlopen(3) once on first use instead of being linked.
+// CreateEncoder and CreateDecoder return ErrNotAvailable if the library cannot be loaded.
+// the headers (xvcenc.h / xvcdec.h) are still required at compile time.
+
+/*
+#cgo CFLAGS: -DGO_XVC_DLOPEN
+#cgo linux LDFLAGS: -ldl -lpthread
+*/
+import "C"
@@ -0,0 +1,15 @@
+//go:build xvc_static
+// +build xvc_static
+
+package xvc
+
+// libxvcenc / libxvcdec are built from third_party/xvc and linked statically.
+// run `go generate -tags xvc_static` before building.
+
+//go:generate sh ./third_party/build-xvc.sh
+
+/*
+#cgo darwin LDFLAGS: ${SRCDIR}/lib/libxvcenc.a ${SRCDIR}/lib/libxvcdec.a -lc++
+#cgo linux  LDFLAGS: ${SRCDIR}/lib/libxvcenc.a ${SRCDIR}/lib/libxvcdec.a -lstdc++ -lm -lpthread
+*/
+import "C"
@@ -0,0 +1,542 @@
+package mkv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+var (
+	ErrNotMatroska     = errors.New("mkv: not a matroska stream")
+	ErrNoXVCTrack      = errors.New("mkv: V_XVC track not found")
+	ErrLacingUnsupport = errors.New("mkv: laced block is not supported")
+	ErrNoCues          = errors.New("mkv: cues not found")
+	ErrUnknownSizeSkip = errors.New("mkv: cannot skip unknown size element")
+	ErrElementSize     = errors.New("mkv: element size exceeds its parent")
+)
+
+type Frame struct {
+	Timestamp time.Duration
+	Keyframe  bool
+	// NALs are length prefixed NAL units, ready for xvc.Decoder.Decode
+	NALs [][]byte
+}
+
+// Demuxer reads frames of the V_XVC track.
+type Demuxer struct {
+	r  io.ReadSeeker
+	br *bufio.Reader
+	// offset of br
+	offset int64
+	// size of r
+	size int64
+
+	segmentDataStart int64
+	segmentEnd       int64
+	timecodeScale    uint64
+	duration         float64
+	cuesPos          int64
+
+	trackNumber  uint64
+	codecPrivate []byte
+	width        int
+	height       int
+
+	clusterEnd      int64
+	clusterTimecode int64
+	cues            []cuePoint
+}
+
+func NewDemuxer(r io.ReadSeeker) (*Demuxer, error) {
+	d := &Demuxer{
+		r:             r,
+		timecodeScale: defaultTimecodeScale,
+		cuesPos:       -1,
+		segmentEnd:    -1,
+		clusterEnd:    -1,
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	d.size = size
+	if err := d.seek(0); err != nil {
+		return nil, err
+	}
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// CodecPrivate returns SegmentHeader NAL unit stored in the track, length prefixed
+func (d *Demuxer) CodecPrivate() []byte {
+	return d.codecPrivate
+}
+
+func (d *Demuxer) Width() int {
+	return d.width
+}
+
+func (d *Demuxer) Height() int {
+	return d.height
+}
+
+func (d *Demuxer) Duration() time.Duration {
+	return time.Duration(d.duration * float64(d.timecodeScale))
+}
+
+func (d *Demuxer) seek(pos int64) error {
+	if _, err := d.r.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	d.br = bufio.NewReader(d.r)
+	d.offset = pos
+	return nil
+}
+
+func (d *Demuxer) readElementHeader() (uint32, uint64, error) {
+	id, idLen, err := readID(d.br)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, sizeLen, err := readSize(d.br)
+	if err != nil {
+		return 0, 0, err
+	}
+	d.offset += int64(idLen + sizeLen)
+	return id, size, nil
+}
+
+// remaining returns the number of bytes up to the end of the innermost element containing offset
+func (d *Demuxer) remaining() int64 {
+	end := d.size
+	if d.segmentEnd != -1 && d.segmentEnd < end {
+		end = d.segmentEnd
+	}
+	if d.clusterEnd != -1 && d.clusterEnd < end {
+		end = d.clusterEnd
+	}
+	return end - d.offset
+}
+
+func (d *Demuxer) readData(size uint64) ([]byte, error) {
+	if size == unknownSize {
+		return nil, ErrUnknownSizeSkip
+	}
+	// size is read from the stream, bound it before allocation
+	if remaining := d.remaining(); remaining < 0 || uint64(remaining) < size {
+		return nil, ErrElementSize
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.br, data); err != nil {
+		return nil, err
+	}
+	d.offset += int64(size)
+	return data, nil
+}
+
+func (d *Demuxer) skip(size uint64) error {
+	if size == unknownSize {
+		return ErrUnknownSizeSkip
+	}
+	if _, err := d.br.Discard(int(size)); err != nil {
+		return err
+	}
+	d.offset += int64(size)
+	return nil
+}
+
+func (d *Demuxer) readHeader() error {
+	id, size, err := d.readElementHeader()
+	if err != nil {
+		return err
+	}
+	if id != idEBML {
+		return ErrNotMatroska
+	}
+	if err := d.skip(size); err != nil {
+		return err
+	}
+
+	id, size, err = d.readElementHeader()
+	if err != nil {
+		return err
+	}
+	if id != idSegment {
+		return ErrNotMatroska
+	}
+	d.segmentDataStart = d.offset
+	if size != unknownSize {
+		d.segmentEnd = d.offset + int64(size)
+	}
+
+	for {
+		pos := d.offset
+		id, size, err := d.readElementHeader()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case idSeekHead:
+			data, err := d.readData(size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseSeekHead(data); err != nil {
+				return err
+			}
+		case idInfo:
+			data, err := d.readData(size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseInfo(data); err != nil {
+				return err
+			}
+		case idTracks:
+			data, err := d.readData(size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseTracks(data); err != nil {
+				return err
+			}
+		case idCues:
+			data, err := d.readData(size)
+			if err != nil {
+				return err
+			}
+			if err := d.parseCues(data); err != nil {
+				return err
+			}
+		case idCluster:
+			if d.trackNumber == 0 {
+				return ErrNoXVCTrack
+			}
+			// rewind to the first cluster
+			return d.seek(pos)
+		default:
+			if err := d.skip(size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *Demuxer) parseSeekHead(data []byte) error {
+	seeks, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, seek := range seeks {
+		if seek.id != idSeek {
+			continue
+		}
+		children, err := parseChildren(seek.data)
+		if err != nil {
+			return err
+		}
+		seekID, seekPos := uint32(0), int64(-1)
+		for _, c := range children {
+			switch c.id {
+			case idSeekID:
+				seekID = uint32(decodeUint(c.data))
+			case idSeekPosition:
+				seekPos = int64(decodeUint(c.data))
+			}
+		}
+		if seekID == idCues && 0 <= seekPos {
+			d.cuesPos = d.segmentDataStart + seekPos
+		}
+	}
+	return nil
+}
+
+func (d *Demuxer) parseInfo(data []byte) error {
+	children, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		switch c.id {
+		case idTimecodeScale:
+			d.timecodeScale = decodeUint(c.data)
+		case idDuration:
+			d.duration = decodeFloat(c.data)
+		}
+	}
+	return nil
+}
+
+func (d *Demuxer) parseTracks(data []byte) error {
+	entries, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.id != idTrackEntry {
+			continue
+		}
+		children, err := parseChildren(entry.data)
+		if err != nil {
+			return err
+		}
+		number, codecID := uint64(0), ""
+		var codecPrivate, video []byte
+		for _, c := range children {
+			switch c.id {
+			case idTrackNumber:
+				number = decodeUint(c.data)
+			case idCodecID:
+				codecID = string(c.data)
+			case idCodecPrivate:
+				codecPrivate = c.data
+			case idVideo:
+				video = c.data
+			}
+		}
+		if codecID != CodecID {
+			continue
+		}
+		d.trackNumber = number
+		d.codecPrivate = codecPrivate
+		if video != nil {
+			props, err := parseChildren(video)
+			if err != nil {
+				return err
+			}
+			for _, p := range props {
+				switch p.id {
+				case idPixelWidth:
+					d.width = int(decodeUint(p.data))
+				case idPixelHeight:
+					d.height = int(decodeUint(p.data))
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func (d *Demuxer) parseCues(data []byte) error {
+	points, err := parseChildren(data)
+	if err != nil {
+		return err
+	}
+	cues := make([]cuePoint, 0, len(points))
+	for _, point := range points {
+		if point.id != idCuePoint {
+			continue
+		}
+		children, err := parseChildren(point.data)
+		if err != nil {
+			return err
+		}
+		cue := cuePoint{}
+		track := uint64(0)
+		for _, c := range children {
+			switch c.id {
+			case idCueTime:
+				cue.time = decodeUint(c.data)
+			case idCueTrackPositions:
+				positions, err := parseChildren(c.data)
+				if err != nil {
+					return err
+				}
+				for _, p := range positions {
+					switch p.id {
+					case idCueTrack:
+						track = decodeUint(p.data)
+					case idCueClusterPosition:
+						cue.position = decodeUint(p.data)
+					}
+				}
+			}
+		}
+		if track == d.trackNumber {
+			cues = append(cues, cue)
+		}
+	}
+	sort.Slice(cues, func(i, j int) bool {
+		return cues[i].time < cues[j].time
+	})
+	d.cues = cues
+	return nil
+}
+
+// ReadFrame returns the next frame of the V_XVC track, io.EOF at the end of segment.
+func (d *Demuxer) ReadFrame() (*Frame, error) {
+	for {
+		if d.segmentEnd != -1 && d.segmentEnd <= d.offset {
+			return nil, io.EOF
+		}
+		if d.clusterEnd != -1 && d.clusterEnd <= d.offset {
+			d.clusterEnd = -1
+		}
+
+		id, size, err := d.readElementHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch id {
+		case idCluster:
+			if size == unknownSize {
+				d.clusterEnd = -1
+			} else {
+				d.clusterEnd = d.offset + int64(size)
+			}
+		case idTimecode:
+			data, err := d.readData(size)
+			if err != nil {
+				return nil, err
+			}
+			d.clusterTimecode = int64(decodeUint(data))
+		case idSimpleBlock:
+			data, err := d.readData(size)
+			if err != nil {
+				return nil, err
+			}
+			frame, err := d.parseBlock(data, true)
+			if err != nil {
+				return nil, err
+			}
+			if frame != nil {
+				return frame, nil
+			}
+		case idBlockGroup:
+			data, err := d.readData(size)
+			if err != nil {
+				return nil, err
+			}
+			children, err := parseChildren(data)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range children {
+				if c.id != idBlock {
+					continue
+				}
+				frame, err := d.parseBlock(c.data, false)
+				if err != nil {
+					return nil, err
+				}
+				if frame != nil {
+					return frame, nil
+				}
+			}
+		default:
+			if err := d.skip(size); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (d *Demuxer) parseBlock(data []byte, simple bool) (*Frame, error) {
+	r := bytes.NewReader(data)
+	track, n, err := readVint(r)
+	if err != nil {
+		return nil, err
+	}
+	track &^= uint64(0x80>>(n-1)) << (8 * (n - 1))
+	if track != d.trackNumber {
+		return nil, nil
+	}
+	if len(data) < n+3 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	relative := int16(binary.BigEndian.Uint16(data[n : n+2]))
+	flags := data[n+2]
+	if (flags & blockFlagLacing) != 0 {
+		return nil, ErrLacingUnsupport
+	}
+
+	nals, err := splitNALs(data[n+3:])
+	if err != nil {
+		return nil, err
+	}
+
+	keyframe := simple && (flags&blockFlagKeyframe) != 0
+	if simple != true {
+		for _, nal := range nals {
+			if xvctype.ParseNALUnitType(nal[4]) == xvctype.IntraAccessPicture {
+				keyframe = true
+			}
+		}
+	}
+
+	timecode := d.clusterTimecode + int64(relative)
+	return &Frame{
+		Timestamp: time.Duration(timecode * int64(d.timecodeScale)),
+		Keyframe:  keyframe,
+		NALs:      nals,
+	}, nil
+}
+
+// Seek positions the demuxer at the cluster of the last keyframe at or before t.
+func (d *Demuxer) Seek(t time.Duration) error {
+	if d.cues == nil {
+		if d.cuesPos < 0 {
+			return ErrNoCues
+		}
+		if err := d.seek(d.cuesPos); err != nil {
+			return err
+		}
+		d.clusterEnd = -1
+		id, size, err := d.readElementHeader()
+		if err != nil {
+			return err
+		}
+		if id != idCues {
+			return ErrNoCues
+		}
+		data, err := d.readData(size)
+		if err != nil {
+			return err
+		}
+		if err := d.parseCues(data); err != nil {
+			return err
+		}
+	}
+	if len(d.cues) < 1 {
+		return ErrNoCues
+	}
+
+	timecode := uint64(int64(t) / int64(d.timecodeScale))
+	i := sort.Search(len(d.cues), func(i int) bool {
+		return timecode < d.cues[i].time
+	})
+	if 0 < i {
+		i -= 1
+	}
+	d.clusterEnd = -1
+	return d.seek(d.segmentDataStart + int64(d.cues[i].position))
+}
+
+// splitNALs splits concatenated length prefixed NAL units
+func splitNALs(data []byte) ([][]byte, error) {
+	nals := make([][]byte, 0, 4)
+	for 0 < len(data) {
+		if len(data) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := 4 + int(binary.LittleEndian.Uint32(data[0:4]))
+		if size == 4 {
+			return nil, ErrInvalidNAL
+		}
+		if len(data) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+		nals = append(nals, data[:size])
+		data = data[size:]
+	}
+	return nals, nil
+}
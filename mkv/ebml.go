@@ -0,0 +1,201 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	unknownSize    uint64 = 0x00ffffffffffffff
+	maxVintLength  int    = 8
+	sizeFieldBytes int    = 8 // size field length of elements patched after writing
+)
+
+var (
+	ErrInvalidVint = errors.New("mkv: invalid ebml variable size integer")
+)
+
+// ebml writer helpers
+
+func encodeID(id uint32) []byte {
+	switch {
+	case id <= 0xff:
+		return []byte{byte(id)}
+	case id <= 0xffff:
+		return []byte{byte(id >> 8), byte(id)}
+	case id <= 0xffffff:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	}
+}
+
+func encodeSize(size uint64) []byte {
+	for n := 1; n < maxVintLength; n += 1 {
+		// all 1 bits are reserved for unknown size
+		if size < (uint64(1)<<(7*n))-1 {
+			return encodeSizeN(size, n)
+		}
+	}
+	return encodeSizeN(size, maxVintLength)
+}
+
+func encodeSizeN(size uint64, n int) []byte {
+	buf := make([]byte, n)
+	for i := n - 1; 0 <= i; i -= 1 {
+		buf[i] = byte(size)
+		size >>= 8
+	}
+	buf[0] |= byte(0x80 >> (n - 1))
+	return buf
+}
+
+func element(id uint32, payload []byte) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 4+8+len(payload)))
+	buf.Write(encodeID(id))
+	buf.Write(encodeSize(uint64(len(payload))))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func master(id uint32, children ...[]byte) []byte {
+	return element(id, bytes.Join(children, nil))
+}
+
+func uintElement(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && (v>>(8*n)) != 0 {
+		n += 1
+	}
+	buf := make([]byte, n)
+	for i := n - 1; 0 <= i; i -= 1 {
+		buf[i] = byte(v)
+		v >>= 8
+	}
+	return element(id, buf)
+}
+
+func floatElement(id uint32, v float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(v))
+	return element(id, buf)
+}
+
+func stringElement(id uint32, v string) []byte {
+	return element(id, []byte(v))
+}
+
+func voidElement(size int) []byte {
+	// size is the total size including id and size field
+	if size < 2 {
+		return nil
+	}
+	if size-2 < 0x7f {
+		return element(idVoid, make([]byte, size-2))
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, encodeID(idVoid)...)
+	buf = append(buf, encodeSizeN(uint64(size-1-sizeFieldBytes), sizeFieldBytes)...)
+	return append(buf, make([]byte, size-1-sizeFieldBytes)...)
+}
+
+// ebml reader helpers
+
+func readVint(r io.ByteReader) (uint64, int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	for mask := byte(0x80); (first & mask) == 0; mask >>= 1 {
+		n += 1
+		if maxVintLength < n {
+			return 0, 0, ErrInvalidVint
+		}
+	}
+	v := uint64(first)
+	for i := 1; i < n; i += 1 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		v = (v << 8) | uint64(b)
+	}
+	return v, n, nil
+}
+
+// readID returns element id with marker bits
+func readID(r io.ByteReader) (uint32, int, error) {
+	v, n, err := readVint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	if 4 < n {
+		return 0, 0, ErrInvalidVint
+	}
+	return uint32(v), n, nil
+}
+
+// readSize returns element size without marker bit, unknownSize for all 1 bits
+func readSize(r io.ByteReader) (uint64, int, error) {
+	v, n, err := readVint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	v &^= uint64(0x80>>(n-1)) << (8 * (n - 1))
+	if v == (uint64(1)<<(7*n))-1 {
+		return unknownSize, n, nil
+	}
+	return v, n, nil
+}
+
+func decodeUint(data []byte) uint64 {
+	v := uint64(0)
+	for _, b := range data {
+		v = (v << 8) | uint64(b)
+	}
+	return v
+}
+
+func decodeFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+type ebmlElement struct {
+	id   uint32
+	data []byte
+}
+
+// parseChildren parses elements of a master element
+func parseChildren(data []byte) ([]ebmlElement, error) {
+	r := bytes.NewReader(data)
+	elements := make([]ebmlElement, 0, 8)
+	for 0 < r.Len() {
+		id, _, err := readID(r)
+		if err != nil {
+			return nil, err
+		}
+		size, _, err := readSize(r)
+		if err != nil {
+			return nil, err
+		}
+		if size == unknownSize || uint64(r.Len()) < size {
+			return nil, ErrInvalidVint
+		}
+		offset := len(data) - r.Len()
+		elements = append(elements, ebmlElement{id, data[offset : offset+int(size)]})
+		if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	return elements, nil
+}
@@ -0,0 +1,76 @@
+// Package mkv implements Matroska muxer and demuxer of xvc streams.
+//
+// the video track has CodecID "V_XVC", and CodecPrivate is the first SegmentHeader NAL unit.
+// each SimpleBlock holds the length prefixed NAL units of a picture (the same format as xvc.NALUnit.Bytes()),
+// SegmentHeader is also kept in-band so that blocks can be passed to xvc.Decoder.Decode as is.
+package mkv
+
+const (
+	CodecID         string = "V_XVC"
+	DocTypeMatroska string = "matroska"
+	DocTypeWebM     string = "webm"
+)
+
+const (
+	idEBML               uint32 = 0x1A45DFA3
+	idEBMLVersion        uint32 = 0x4286
+	idEBMLReadVersion    uint32 = 0x42F7
+	idEBMLMaxIDLength    uint32 = 0x42F2
+	idEBMLMaxSizeLength  uint32 = 0x42F3
+	idDocType            uint32 = 0x4282
+	idDocTypeVersion     uint32 = 0x4287
+	idDocTypeReadVersion uint32 = 0x4285
+	idVoid               uint32 = 0xEC
+
+	idSegment      uint32 = 0x18538067
+	idSeekHead     uint32 = 0x114D9B74
+	idSeek         uint32 = 0x4DBB
+	idSeekID       uint32 = 0x53AB
+	idSeekPosition uint32 = 0x53AC
+
+	idInfo          uint32 = 0x1549A966
+	idTimecodeScale uint32 = 0x2AD7B1
+	idDuration      uint32 = 0x4489
+	idMuxingApp     uint32 = 0x4D80
+	idWritingApp    uint32 = 0x5741
+
+	idTracks       uint32 = 0x1654AE6B
+	idTrackEntry   uint32 = 0xAE
+	idTrackNumber  uint32 = 0xD7
+	idTrackUID     uint32 = 0x73C5
+	idTrackType    uint32 = 0x83
+	idFlagLacing   uint32 = 0x9C
+	idCodecID      uint32 = 0x86
+	idCodecPrivate uint32 = 0x63A2
+	idVideo        uint32 = 0xE0
+	idPixelWidth   uint32 = 0xB0
+	idPixelHeight  uint32 = 0xBA
+
+	idCluster     uint32 = 0x1F43B675
+	idTimecode    uint32 = 0xE7
+	idSimpleBlock uint32 = 0xA3
+	idBlockGroup  uint32 = 0xA0
+	idBlock       uint32 = 0xA1
+
+	idCues               uint32 = 0x1C53BB6B
+	idCuePoint           uint32 = 0xBB
+	idCueTime            uint32 = 0xB3
+	idCueTrackPositions  uint32 = 0xB7
+	idCueTrack           uint32 = 0xF7
+	idCueClusterPosition uint32 = 0xF1
+)
+
+const (
+	trackTypeVideo uint64 = 1
+
+	defaultTimecodeScale uint64 = 1000000 // 1ms
+	videoTrackNumber     uint64 = 1
+
+	blockFlagKeyframe byte = 0x80
+	blockFlagLacing   byte = 0x06
+)
+
+type cuePoint struct {
+	time     uint64 // in timecode scale
+	position uint64 // cluster position relative to segment data
+}
@@ -0,0 +1,213 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+// memFile is in-memory io.WriteSeeker / io.ReadSeeker
+type memFile struct {
+	buf []byte
+	pos int64
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	end := int(f.pos) + len(p)
+	if len(f.buf) < end {
+		f.buf = append(f.buf, make([]byte, end-len(f.buf))...)
+	}
+	copy(f.buf[f.pos:], p)
+	f.pos = int64(end)
+	return len(p), nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if int64(len(f.buf)) <= f.pos {
+		return 0, io.EOF
+	}
+	n := copy(p, f.buf[f.pos:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.pos = offset
+	case io.SeekCurrent:
+		f.pos += offset
+	case io.SeekEnd:
+		f.pos = int64(len(f.buf)) + offset
+	}
+	return f.pos, nil
+}
+
+func testNAL(typ xvctype.NALUnitType, size int, seed byte) []byte {
+	nal := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(nal[0:4], uint32(size))
+	nal[4] = byte(typ) << 1
+	for i := 5; i < len(nal); i += 1 {
+		nal[i] = seed + byte(i)
+	}
+	return nal
+}
+
+func testFrames(n, keyInterval int, duration time.Duration) []Frame {
+	frames := make([]Frame, n)
+	for i := 0; i < n; i += 1 {
+		if i%keyInterval == 0 {
+			frames[i] = Frame{
+				Timestamp: time.Duration(i) * duration,
+				Keyframe:  true,
+				NALs: [][]byte{
+					testNAL(xvctype.SegmentHeader, 16, 0),
+					testNAL(xvctype.IntraAccessPicture, 200, byte(i)),
+				},
+			}
+			continue
+		}
+		frames[i] = Frame{
+			Timestamp: time.Duration(i) * duration,
+			Keyframe:  false,
+			NALs: [][]byte{
+				testNAL(xvctype.PredictedPicture, 50, byte(i)),
+			},
+		}
+	}
+	return frames
+}
+
+func assertFrame(t *testing.T, expect Frame, actual *Frame) {
+	t.Helper()
+
+	if expect.Timestamp != actual.Timestamp {
+		t.Errorf("expect timestamp %s actual %s", expect.Timestamp, actual.Timestamp)
+	}
+	if expect.Keyframe != actual.Keyframe {
+		t.Errorf("frame %s: expect keyframe %v actual %v", expect.Timestamp, expect.Keyframe, actual.Keyframe)
+	}
+	if len(expect.NALs) != len(actual.NALs) {
+		t.Fatalf("frame %s: expect %d nals actual %d", expect.Timestamp, len(expect.NALs), len(actual.NALs))
+	}
+	for i := range expect.NALs {
+		if bytes.Equal(expect.NALs[i], actual.NALs[i]) != true {
+			t.Errorf("frame %s: nal[%d] not same", expect.Timestamp, i)
+		}
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	frames := testFrames(30, 10, 33*time.Millisecond)
+
+	f := &memFile{}
+	m, err := NewMuxer(f, 320, 240)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	for _, frame := range frames {
+		if err := m.WriteFrame(frame.Timestamp, frame.NALs...); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	t.Run("read", func(tt *testing.T) {
+		d, err := NewDemuxer(bytes.NewReader(f.buf))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if d.Width() != 320 || d.Height() != 240 {
+			tt.Errorf("expect 320x240 actual %dx%d", d.Width(), d.Height())
+		}
+		if bytes.Equal(d.CodecPrivate(), frames[0].NALs[0]) != true {
+			tt.Errorf("expect codec private is segment header")
+		}
+		if d.Duration() < frames[len(frames)-1].Timestamp {
+			tt.Errorf("expect duration >= %s actual %s", frames[len(frames)-1].Timestamp, d.Duration())
+		}
+
+		for _, expect := range frames {
+			frame, err := d.ReadFrame()
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			assertFrame(tt, expect, frame)
+		}
+		if _, err := d.ReadFrame(); err != io.EOF {
+			tt.Errorf("expect io.EOF actual %v", err)
+		}
+	})
+	t.Run("seek", func(tt *testing.T) {
+		d, err := NewDemuxer(bytes.NewReader(f.buf))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+
+		// keyframe at or before the target
+		if err := d.Seek(500 * time.Millisecond); err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		for _, expect := range frames[10:] {
+			frame, err := d.ReadFrame()
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			assertFrame(tt, expect, frame)
+		}
+
+		// backward
+		if err := d.Seek(0); err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		frame, err := d.ReadFrame()
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		assertFrame(tt, frames[0], frame)
+
+		// after the last keyframe
+		if err := d.Seek(10 * time.Second); err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		frame, err = d.ReadFrame()
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		assertFrame(tt, frames[20], frame)
+	})
+}
+
+func TestSplitNALs(t *testing.T) {
+	t.Run("zero length", func(tt *testing.T) {
+		for _, data := range [][]byte{
+			append([]byte{0, 0, 0, 0}, testNAL(xvctype.PredictedPicture, 10, 0)...),
+			append(testNAL(xvctype.PredictedPicture, 10, 0), 0, 0, 0, 0),
+		} {
+			if _, err := splitNALs(data); err != ErrInvalidNAL {
+				tt.Errorf("expect ErrInvalidNAL actual %v", err)
+			}
+		}
+	})
+	t.Run("truncated", func(tt *testing.T) {
+		data := testNAL(xvctype.PredictedPicture, 10, 0)
+		if _, err := splitNALs(data[:len(data)-1]); err != io.ErrUnexpectedEOF {
+			tt.Errorf("expect io.ErrUnexpectedEOF actual %v", err)
+		}
+	})
+}
+
+func TestDemuxerElementSize(t *testing.T) {
+	// Info claims 1TB in a small file
+	info := append(encodeID(idInfo), encodeSizeN(1<<40, 8)...)
+	data := append(master(idEBML), master(idSegment, info)...)
+	if _, err := NewDemuxer(bytes.NewReader(data)); err != ErrElementSize {
+		t.Errorf("expect ErrElementSize actual %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package mkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	seekHeadReserveSize int   = 128
+	maxClusterDuration  int64 = 5000 // in timecode scale
+)
+
+var (
+	ErrMuxerClosed = errors.New("mkv: muxer already closed")
+	ErrInvalidNAL  = errors.New("mkv: invalid nal unit")
+)
+
+type muxerOptionFunc func(*muxerOption)
+type muxerOption struct {
+	docType    string
+	writingApp string
+}
+
+func MuxerDocType(docType string) muxerOptionFunc {
+	return func(o *muxerOption) {
+		o.docType = docType
+	}
+}
+
+func MuxerWritingApp(app string) muxerOptionFunc {
+	return func(o *muxerOption) {
+		o.writingApp = app
+	}
+}
+
+type Muxer struct {
+	w      io.WriteSeeker
+	opt    *muxerOption
+	width  int
+	height int
+
+	segmentSizePos   int64
+	segmentDataStart int64
+	durationPos      int64
+	infoPos          int64
+	tracksPos        int64
+	tracksWritten    bool
+
+	cluster         *bytes.Buffer
+	clusterTimecode int64
+	clusterPos      int64
+	maxTimecode     int64
+	cues            []cuePoint
+	closed          bool
+}
+
+// NewMuxer writes EBML header, Segment and Info to w.
+// Tracks is written with the first frame to take the SegmentHeader as CodecPrivate.
+func NewMuxer(w io.WriteSeeker, width, height int, funcs ...muxerOptionFunc) (*Muxer, error) {
+	opt := &muxerOption{
+		docType:    DocTypeMatroska,
+		writingApp: "go-xvc",
+	}
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	m := &Muxer{
+		w:       w,
+		opt:     opt,
+		width:   width,
+		height:  height,
+		cluster: nil,
+		cues:    make([]cuePoint, 0, 64),
+	}
+	if err := m.writeHeader(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Muxer) pos() (int64, error) {
+	return m.w.Seek(0, io.SeekCurrent)
+}
+
+func (m *Muxer) writeHeader() error {
+	ebmlHeader := master(idEBML,
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		stringElement(idDocType, m.opt.docType),
+		uintElement(idDocTypeVersion, 4),
+		uintElement(idDocTypeReadVersion, 2),
+	)
+	if _, err := m.w.Write(ebmlHeader); err != nil {
+		return err
+	}
+
+	if _, err := m.w.Write(encodeID(idSegment)); err != nil {
+		return err
+	}
+	segmentSizePos, err := m.pos()
+	if err != nil {
+		return err
+	}
+	m.segmentSizePos = segmentSizePos
+	// unknown size until Close
+	if _, err := m.w.Write(encodeSizeN(unknownSize, sizeFieldBytes)); err != nil {
+		return err
+	}
+	m.segmentDataStart = segmentSizePos + int64(sizeFieldBytes)
+
+	// reserved for SeekHead
+	if _, err := m.w.Write(voidElement(seekHeadReserveSize)); err != nil {
+		return err
+	}
+
+	infoPos, err := m.pos()
+	if err != nil {
+		return err
+	}
+	m.infoPos = infoPos
+	durationElement := floatElement(idDuration, 0)
+	info := master(idInfo,
+		uintElement(idTimecodeScale, defaultTimecodeScale),
+		stringElement(idMuxingApp, "go-xvc"),
+		stringElement(idWritingApp, m.opt.writingApp),
+		durationElement,
+	)
+	// Duration is the last child, its value is patched at Close
+	m.durationPos = infoPos + int64(len(info)) - 8
+	if _, err := m.w.Write(info); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Muxer) writeTracks(codecPrivate []byte) error {
+	tracksPos, err := m.pos()
+	if err != nil {
+		return err
+	}
+	m.tracksPos = tracksPos
+
+	entry := [][]byte{
+		uintElement(idTrackNumber, videoTrackNumber),
+		uintElement(idTrackUID, videoTrackNumber),
+		uintElement(idTrackType, trackTypeVideo),
+		uintElement(idFlagLacing, 0),
+		stringElement(idCodecID, CodecID),
+	}
+	if codecPrivate != nil {
+		entry = append(entry, element(idCodecPrivate, codecPrivate))
+	}
+	entry = append(entry, master(idVideo,
+		uintElement(idPixelWidth, uint64(m.width)),
+		uintElement(idPixelHeight, uint64(m.height)),
+	))
+
+	if _, err := m.w.Write(master(idTracks, master(idTrackEntry, entry...))); err != nil {
+		return err
+	}
+	m.tracksWritten = true
+	return nil
+}
+
+// WriteFrame writes length prefixed NAL units of a picture (e.g. xvc.NALUnit.Bytes()) as a SimpleBlock.
+// pts is presentation timestamp, the block is flagged as keyframe when nals contain IntraAccessPicture.
+func (m *Muxer) WriteFrame(pts time.Duration, nals ...[]byte) error {
+	if m.closed {
+		return ErrMuxerClosed
+	}
+
+	keyframe := false
+	var segmentHeader []byte
+	for _, nal := range nals {
+		if len(nal) < 5 || len(nal) < 4+int(binary.LittleEndian.Uint32(nal[0:4])) {
+			return ErrInvalidNAL
+		}
+		switch xvctype.ParseNALUnitType(nal[4]) {
+		case xvctype.SegmentHeader:
+			if segmentHeader == nil {
+				segmentHeader = nal
+			}
+		case xvctype.IntraAccessPicture:
+			keyframe = true
+		}
+	}
+
+	if m.tracksWritten != true {
+		if err := m.writeTracks(segmentHeader); err != nil {
+			return err
+		}
+	}
+
+	timecode := int64(pts) / int64(defaultTimecodeScale)
+	if m.cluster != nil {
+		relative := timecode - m.clusterTimecode
+		if keyframe || relative < math.MinInt16 || math.MaxInt16 < relative || maxClusterDuration < relative {
+			if err := m.flushCluster(); err != nil {
+				return err
+			}
+		}
+	}
+	if m.cluster == nil {
+		if err := m.startCluster(timecode); err != nil {
+			return err
+		}
+		if keyframe {
+			m.cues = append(m.cues, cuePoint{
+				time:     uint64(timecode),
+				position: uint64(m.clusterPos - m.segmentDataStart),
+			})
+		}
+	}
+
+	data := bytes.Join(nals, nil)
+	block := make([]byte, 0, 4+len(data))
+	block = append(block, encodeSize(videoTrackNumber)...)
+	relative := int16(timecode - m.clusterTimecode)
+	block = append(block, byte(uint16(relative)>>8), byte(uint16(relative)))
+	flags := byte(0)
+	if keyframe {
+		flags |= blockFlagKeyframe
+	}
+	block = append(block, flags)
+	block = append(block, data...)
+	m.cluster.Write(element(idSimpleBlock, block))
+
+	if m.maxTimecode < timecode {
+		m.maxTimecode = timecode
+	}
+	return nil
+}
+
+func (m *Muxer) startCluster(timecode int64) error {
+	clusterPos, err := m.pos()
+	if err != nil {
+		return err
+	}
+	m.clusterPos = clusterPos
+	m.clusterTimecode = timecode
+	m.cluster = bytes.NewBuffer(make([]byte, 0, 64*1024))
+	m.cluster.Write(uintElement(idTimecode, uint64(timecode)))
+	return nil
+}
+
+func (m *Muxer) flushCluster() error {
+	if m.cluster == nil {
+		return nil
+	}
+	if _, err := m.w.Write(element(idCluster, m.cluster.Bytes())); err != nil {
+		return err
+	}
+	m.cluster = nil
+	return nil
+}
+
+// Close writes Cues and SeekHead, and fixes the Segment size and Duration.
+func (m *Muxer) Close() error {
+	if m.closed {
+		return ErrMuxerClosed
+	}
+	m.closed = true
+
+	if m.tracksWritten != true {
+		if err := m.writeTracks(nil); err != nil {
+			return err
+		}
+	}
+	if err := m.flushCluster(); err != nil {
+		return err
+	}
+
+	cuesPos, err := m.pos()
+	if err != nil {
+		return err
+	}
+	cuePoints := make([][]byte, len(m.cues))
+	for i, c := range m.cues {
+		cuePoints[i] = master(idCuePoint,
+			uintElement(idCueTime, c.time),
+			master(idCueTrackPositions,
+				uintElement(idCueTrack, videoTrackNumber),
+				uintElement(idCueClusterPosition, c.position),
+			),
+		)
+	}
+	hasCues := 0 < len(cuePoints)
+	if hasCues {
+		if _, err := m.w.Write(master(idCues, cuePoints...)); err != nil {
+			return err
+		}
+	}
+
+	endPos, err := m.pos()
+	if err != nil {
+		return err
+	}
+
+	seeks := [][]byte{
+		m.seekEntry(idInfo, m.infoPos),
+		m.seekEntry(idTracks, m.tracksPos),
+	}
+	if hasCues {
+		seeks = append(seeks, m.seekEntry(idCues, cuesPos))
+	}
+	seekHead := master(idSeekHead, seeks...)
+	seekHead = append(seekHead, voidElement(seekHeadReserveSize-len(seekHead))...)
+	if err := m.writeAt(m.segmentDataStart, seekHead); err != nil {
+		return err
+	}
+
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(float64(m.maxTimecode)))
+	if err := m.writeAt(m.durationPos, duration); err != nil {
+		return err
+	}
+
+	segmentSize := uint64(endPos - m.segmentDataStart)
+	if err := m.writeAt(m.segmentSizePos, encodeSizeN(segmentSize, sizeFieldBytes)); err != nil {
+		return err
+	}
+
+	if _, err := m.w.Seek(endPos, io.SeekStart); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Muxer) seekEntry(id uint32, pos int64) []byte {
+	return master(idSeek,
+		element(idSeekID, encodeID(id)),
+		uintElement(idSeekPosition, uint64(pos-m.segmentDataStart)),
+	)
+}
+
+func (m *Muxer) writeAt(pos int64, data []byte) error {
+	if _, err := m.w.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := m.w.Write(data); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package mp4
+
+import (
+	"encoding/binary"
+)
+
+type boxWriter struct {
+	buf []byte
+}
+
+func newBoxWriter(size int) *boxWriter {
+	return &boxWriter{make([]byte, 0, size)}
+}
+
+func (w *boxWriter) u8(v uint8) *boxWriter {
+	w.buf = append(w.buf, v)
+	return w
+}
+
+func (w *boxWriter) u16(v uint16) *boxWriter {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+	return w
+}
+
+func (w *boxWriter) u32(v uint32) *boxWriter {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return w
+}
+
+func (w *boxWriter) u64(v uint64) *boxWriter {
+	w.u32(uint32(v >> 32))
+	w.u32(uint32(v))
+	return w
+}
+
+func (w *boxWriter) bytes(b []byte) *boxWriter {
+	w.buf = append(w.buf, b...)
+	return w
+}
+
+func (w *boxWriter) zeros(n int) *boxWriter {
+	w.buf = append(w.buf, make([]byte, n)...)
+	return w
+}
+
+func (w *boxWriter) Bytes() []byte {
+	return w.buf
+}
+
+func box(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+	w := newBoxWriter(size)
+	w.u32(uint32(size))
+	w.bytes([]byte(typ))
+	for _, p := range payloads {
+		w.bytes(p)
+	}
+	return w.Bytes()
+}
+
+func fullBox(typ string, version uint8, flags uint32, payloads ...[]byte) []byte {
+	header := newBoxWriter(4).u32((uint32(version) << 24) | (flags & 0x00ffffff)).Bytes()
+	return box(typ, append([][]byte{header}, payloads...)...)
+}
+
+type rawBox struct {
+	typ  string
+	data []byte // payload without header
+}
+
+// parseBoxes parses sibling boxes in data
+func parseBoxes(data []byte) ([]rawBox, error) {
+	boxes := make([]rawBox, 0, 8)
+	for 0 < len(data) {
+		if len(data) < 8 {
+			return nil, ErrInvalidBox
+		}
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		typ := string(data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, ErrInvalidBox
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || uint64(len(data)) < size {
+			return nil, ErrInvalidBox
+		}
+		boxes = append(boxes, rawBox{typ, data[headerSize:size]})
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []rawBox, typ string) (rawBox, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return rawBox{}, false
+}
+
+// findPath finds nested box by path e.g. "mdia", "minf", "stbl"
+func findPath(data []byte, path ...string) ([]byte, bool) {
+	for _, typ := range path {
+		boxes, err := parseBoxes(data)
+		if err != nil {
+			return nil, false
+		}
+		b, ok := findBox(boxes, typ)
+		if ok != true {
+			return nil, false
+		}
+		data = b.data
+	}
+	return data, true
+}
@@ -0,0 +1,216 @@
+package mp4
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+type pendingSample struct {
+	dts  int64
+	pts  int64
+	sync bool
+	data []byte
+}
+
+// FragmentWriter writes fragmented MP4, the init segment (ftyp + moov) is written with the first fragment
+// and a fragment (moof + mdat) starts at each sync sample or when fragment duration is exceeded.
+type FragmentWriter struct {
+	w      io.Writer
+	opt    *writerOption
+	width  int
+	height int
+
+	initWritten   bool
+	started       bool
+	firstDTS      int64
+	segmentHeader []byte
+	sequence      uint32
+	pending       *pendingSample
+	fragment      []pendingSample
+	durations     []uint32
+	closed        bool
+}
+
+func NewFragmentWriter(w io.Writer, width, height int, funcs ...writerOptionFunc) *FragmentWriter {
+	opt := defaultWriterOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+	return &FragmentWriter{
+		w:        w,
+		opt:      opt,
+		width:    width,
+		height:   height,
+		fragment: make([]pendingSample, 0, 64),
+	}
+}
+
+// WriteSample writes length prefixed NAL units of a picture (e.g. xvc.NALUnit.Bytes()) in decoding order.
+// the duration of a sample is decided by the DTS of the next sample, so samples are written with one sample delay.
+func (f *FragmentWriter) WriteSample(dts, pts time.Duration, nals ...[]byte) error {
+	if f.closed {
+		return ErrWriterClose
+	}
+	sync, segmentHeader, err := inspectNALs(nals)
+	if err != nil {
+		return err
+	}
+	if f.segmentHeader == nil && segmentHeader != nil {
+		f.segmentHeader = append([]byte(nil), segmentHeader...)
+	}
+
+	// decoding time starts at 0
+	if f.started != true {
+		f.firstDTS = toTimescale(dts, f.opt.timescale)
+		f.started = true
+	}
+	next := &pendingSample{
+		dts:  toTimescale(dts, f.opt.timescale) - f.firstDTS,
+		pts:  toTimescale(pts, f.opt.timescale) - f.firstDTS,
+		sync: sync,
+		data: bytes.Join(nals, nil),
+	}
+	if f.pending != nil {
+		if err := f.push(*f.pending, uint32(next.dts-f.pending.dts)); err != nil {
+			return err
+		}
+	}
+	f.pending = next
+	return nil
+}
+
+func (f *FragmentWriter) push(s pendingSample, duration uint32) error {
+	if 0 < len(f.fragment) {
+		first := f.fragment[0]
+		elapsed := fromTimescale(s.dts-first.dts, f.opt.timescale)
+		if s.sync || f.opt.fragmentDuration <= elapsed {
+			if err := f.flushFragment(); err != nil {
+				return err
+			}
+		}
+	}
+	f.fragment = append(f.fragment, s)
+	f.durations = append(f.durations, duration)
+	return nil
+}
+
+// Flush writes buffered samples as a fragment
+func (f *FragmentWriter) Flush() error {
+	return f.flushFragment()
+}
+
+// Close writes the last sample and fragment
+func (f *FragmentWriter) Close() error {
+	if f.closed {
+		return ErrWriterClose
+	}
+	f.closed = true
+
+	if f.pending != nil {
+		duration := uint32(0)
+		if 0 < len(f.durations) {
+			duration = f.durations[len(f.durations)-1]
+		}
+		if err := f.push(*f.pending, duration); err != nil {
+			return err
+		}
+		f.pending = nil
+	}
+	return f.flushFragment()
+}
+
+func (f *FragmentWriter) writeInit() error {
+	stbl := box("stbl",
+		stsdBox(f.width, f.height, f.segmentHeader),
+		fullBox("stts", 0, 0, newBoxWriter(4).u32(0).Bytes()),
+		fullBox("stsc", 0, 0, newBoxWriter(4).u32(0).Bytes()),
+		fullBox("stsz", 0, 0, newBoxWriter(8).u32(0).u32(0).Bytes()),
+		fullBox("stco", 0, 0, newBoxWriter(4).u32(0).Bytes()),
+	)
+	minf := box("minf", fullBox("vmhd", 0, 0x000001, newBoxWriter(8).zeros(8).Bytes()), dinfBox(), stbl)
+	mdia := box("mdia", mdhdBox(f.opt.timescale, 0), hdlrBox(), minf)
+	// presentation starts at the first picture of the first fragment
+	mediaTime := f.fragment[0].pts
+	for _, s := range f.fragment {
+		if s.pts < mediaTime {
+			mediaTime = s.pts
+		}
+	}
+	trak := box("trak", tkhdBox(0, f.width, f.height), edtsBox(0, mediaTime), mdia)
+
+	trex := newBoxWriter(20)
+	trex.u32(trackID).u32(1) // track_ID, default_sample_description_index
+	trex.u32(0).u32(0).u32(0)
+	mvex := box("mvex", fullBox("trex", 0, 0, trex.Bytes()))
+
+	moov := box("moov", mvhdBox(f.opt.timescale, 0), trak, mvex)
+	if _, err := f.w.Write(ftypBox()); err != nil {
+		return err
+	}
+	if _, err := f.w.Write(moov); err != nil {
+		return err
+	}
+	f.initWritten = true
+	return nil
+}
+
+func (f *FragmentWriter) flushFragment() error {
+	if len(f.fragment) < 1 {
+		return nil
+	}
+	if f.initWritten != true {
+		if err := f.writeInit(); err != nil {
+			return err
+		}
+	}
+	f.sequence += 1
+
+	moof := f.moofBox(0)
+	// data_offset is relative to moof, fixed after the size of moof is known
+	moof = f.moofBox(uint32(len(moof) + 8))
+
+	size := 8
+	for _, s := range f.fragment {
+		size += len(s.data)
+	}
+	mdat := newBoxWriter(size).u32(uint32(size)).bytes([]byte("mdat"))
+	for _, s := range f.fragment {
+		mdat.bytes(s.data)
+	}
+
+	if _, err := f.w.Write(moof); err != nil {
+		return err
+	}
+	if _, err := f.w.Write(mdat.Bytes()); err != nil {
+		return err
+	}
+	f.fragment = f.fragment[:0]
+	f.durations = f.durations[:0]
+	return nil
+}
+
+func (f *FragmentWriter) moofBox(dataOffset uint32) []byte {
+	mfhd := fullBox("mfhd", 0, 0, newBoxWriter(4).u32(f.sequence).Bytes())
+	tfhd := fullBox("tfhd", 0, 0x020000, newBoxWriter(4).u32(trackID).Bytes()) // default-base-is-moof
+	tfdt := fullBox("tfdt", 1, 0, newBoxWriter(8).u64(uint64(f.fragment[0].dts)).Bytes())
+
+	entries := newBoxWriter(16 * len(f.fragment))
+	for i, s := range f.fragment {
+		flags := sampleFlagNoSync
+		if s.sync {
+			flags = sampleFlagSync
+		}
+		entries.u32(f.durations[i])
+		entries.u32(uint32(len(s.data)))
+		entries.u32(flags)
+		entries.u32(uint32(int32(s.pts - s.dts)))
+	}
+	// data-offset | sample-duration | sample-size | sample-flags | sample-composition-time-offset
+	trunFlags := uint32(0x000001 | 0x000100 | 0x000200 | 0x000400 | 0x000800)
+	trun := fullBox("trun", 1, trunFlags,
+		newBoxWriter(8).u32(uint32(len(f.fragment))).u32(dataOffset).Bytes(),
+		entries.Bytes(),
+	)
+	return box("moof", mfhd, box("traf", tfhd, tfdt, trun))
+}
@@ -0,0 +1,226 @@
+// Package mp4 implements ISO-BMFF (MP4 and fragmented MP4) packaging of xvc streams.
+//
+// the sample entry is 'xvc1' and its 'xvcC' box carries the SegmentHeader NAL unit:
+//
+//	aligned(8) class XVCConfigurationBox extends Box('xvcC') {
+//	  unsigned int(8)  configurationVersion = 1;
+//	  unsigned int(16) segmentHeaderLength;
+//	  bit(8*segmentHeaderLength) segmentHeaderNALUnit;
+//	}
+//
+// a sample holds the length prefixed NAL units of a picture (the same format as xvc.NALUnit.Bytes()),
+// so that the NAL units of a sample can be passed to xvc.Decoder.Decode as is.
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	SampleEntryType string = "xvc1"
+	ConfigBoxType   string = "xvcC"
+
+	DefaultTimescale uint32 = 90000
+)
+
+const (
+	trackID          uint32 = 1
+	configVersion    uint8  = 1
+	sampleFlagSync   uint32 = 0x02000000 // sample_depends_on = 2
+	sampleFlagNoSync uint32 = 0x01010000 // sample_depends_on = 1, sample_is_non_sync_sample = 1
+)
+
+var (
+	ErrInvalidBox  = errors.New("mp4: invalid box")
+	ErrInvalidNAL  = errors.New("mp4: invalid nal unit")
+	ErrNoXVCTrack  = errors.New("mp4: xvc1 track not found")
+	ErrWriterClose = errors.New("mp4: writer already closed")
+)
+
+// Sample is a picture of the track.
+// timestamps are relative to the first presentation timestamp of the track.
+type Sample struct {
+	DTS  time.Duration
+	PTS  time.Duration
+	Sync bool
+	// NALs are length prefixed NAL units, ready for xvc.Decoder.Decode
+	NALs [][]byte
+}
+
+type writerOptionFunc func(*writerOption)
+type writerOption struct {
+	timescale        uint32
+	fragmentDuration time.Duration
+}
+
+func WriterTimescale(timescale uint32) writerOptionFunc {
+	return func(o *writerOption) {
+		o.timescale = timescale
+	}
+}
+
+// WriterFragmentDuration sets the maximum duration of a fragment of FragmentWriter,
+// a new fragment always starts at a sync sample.
+func WriterFragmentDuration(d time.Duration) writerOptionFunc {
+	return func(o *writerOption) {
+		o.fragmentDuration = d
+	}
+}
+
+func defaultWriterOption() *writerOption {
+	return &writerOption{
+		timescale:        DefaultTimescale,
+		fragmentDuration: 2 * time.Second,
+	}
+}
+
+// inspectNALs validates length prefixed NAL units,
+// and returns whether a sync sample (IntraAccessPicture) and the SegmentHeader NAL unit without prefix.
+func inspectNALs(nals [][]byte) (bool, []byte, error) {
+	sync := false
+	var segmentHeader []byte
+	for _, nal := range nals {
+		if len(nal) < 5 {
+			return false, nil, ErrInvalidNAL
+		}
+		size := int(binary.LittleEndian.Uint32(nal[0:4]))
+		if len(nal) < 4+size {
+			return false, nil, ErrInvalidNAL
+		}
+		switch xvctype.ParseNALUnitType(nal[4]) {
+		case xvctype.SegmentHeader:
+			if segmentHeader == nil {
+				segmentHeader = nal[4 : 4+size]
+			}
+		case xvctype.IntraAccessPicture:
+			sync = true
+		}
+	}
+	return sync, segmentHeader, nil
+}
+
+func toTimescale(d time.Duration, timescale uint32) int64 {
+	return int64(d) * int64(timescale) / int64(time.Second)
+}
+
+func fromTimescale(v int64, timescale uint32) time.Duration {
+	return time.Duration(v * int64(time.Second) / int64(timescale))
+}
+
+// splitNALs splits concatenated length prefixed NAL units
+func splitNALs(data []byte) ([][]byte, error) {
+	nals := make([][]byte, 0, 4)
+	for 0 < len(data) {
+		if len(data) < 5 {
+			return nil, ErrInvalidNAL
+		}
+		size := 4 + int(binary.LittleEndian.Uint32(data[0:4]))
+		if len(data) < size {
+			return nil, ErrInvalidNAL
+		}
+		nals = append(nals, data[:size])
+		data = data[size:]
+	}
+	return nals, nil
+}
+
+// ftypBox returns ftyp box, iso6 brand covers fragmented files
+func ftypBox() []byte {
+	w := newBoxWriter(24)
+	w.bytes([]byte("isom")).u32(0x200)
+	w.bytes([]byte("isom")).bytes([]byte("iso6")).bytes([]byte("mp41"))
+	return box("ftyp", w.Bytes())
+}
+
+var identityMatrix = []uint32{
+	0x00010000, 0, 0,
+	0, 0x00010000, 0,
+	0, 0, 0x40000000,
+}
+
+func mvhdBox(timescale uint32, duration uint64) []byte {
+	w := newBoxWriter(96)
+	w.u32(0).u32(0) // creation_time, modification_time
+	w.u32(timescale).u32(uint32(duration))
+	w.u32(0x00010000) // rate
+	w.u16(0x0100)     // volume
+	w.zeros(2 + 8)    // reserved
+	for _, v := range identityMatrix {
+		w.u32(v)
+	}
+	w.zeros(24)        // pre_defined
+	w.u32(trackID + 1) // next_track_ID
+	return fullBox("mvhd", 0, 0, w.Bytes())
+}
+
+func tkhdBox(duration uint64, width, height int) []byte {
+	w := newBoxWriter(80)
+	w.u32(0).u32(0) // creation_time, modification_time
+	w.u32(trackID)
+	w.zeros(4) // reserved
+	w.u32(uint32(duration))
+	w.zeros(8)      // reserved
+	w.u16(0).u16(0) // layer, alternate_group
+	w.u16(0)        // volume
+	w.zeros(2)      // reserved
+	for _, v := range identityMatrix {
+		w.u32(v)
+	}
+	w.u32(uint32(width) << 16).u32(uint32(height) << 16)
+	return fullBox("tkhd", 0, 0x000003, w.Bytes()) // enabled | in_movie
+}
+
+// edtsBox returns edit list that starts presentation at mediaTime
+func edtsBox(duration uint64, mediaTime int64) []byte {
+	w := newBoxWriter(16)
+	w.u32(1) // entry_count
+	w.u32(uint32(duration)).u32(uint32(int32(mediaTime)))
+	w.u16(1).u16(0) // media_rate
+	return box("edts", fullBox("elst", 0, 0, w.Bytes()))
+}
+
+func mdhdBox(timescale uint32, duration uint64) []byte {
+	w := newBoxWriter(20)
+	w.u32(0).u32(0) // creation_time, modification_time
+	w.u32(timescale).u32(uint32(duration))
+	w.u16(0x55c4) // und
+	w.u16(0)
+	return fullBox("mdhd", 0, 0, w.Bytes())
+}
+
+func hdlrBox() []byte {
+	w := newBoxWriter(32)
+	w.u32(0) // pre_defined
+	w.bytes([]byte("vide"))
+	w.zeros(12)
+	w.bytes([]byte("VideoHandler\x00"))
+	return fullBox("hdlr", 0, 0, w.Bytes())
+}
+
+func dinfBox() []byte {
+	url := fullBox("url ", 0, 0x000001) // self contained
+	dref := fullBox("dref", 0, 0, newBoxWriter(4).u32(1).Bytes(), url)
+	return box("dinf", dref)
+}
+
+func stsdBox(width, height int, segmentHeader []byte) []byte {
+	config := newBoxWriter(3 + len(segmentHeader))
+	config.u8(configVersion).u16(uint16(len(segmentHeader))).bytes(segmentHeader)
+
+	w := newBoxWriter(78)
+	w.zeros(6).u16(1) // reserved, data_reference_index
+	w.zeros(16)       // pre_defined, reserved
+	w.u16(uint16(width)).u16(uint16(height))
+	w.u32(0x00480000).u32(0x00480000) // 72 dpi
+	w.zeros(4)                        // reserved
+	w.u16(1)                          // frame_count
+	w.zeros(32)                       // compressorname
+	w.u16(0x0018).u16(0xffff)         // depth, pre_defined
+	entry := box(SampleEntryType, w.Bytes(), box(ConfigBoxType, config.Bytes()))
+
+	return fullBox("stsd", 0, 0, newBoxWriter(4).u32(1).Bytes(), entry)
+}
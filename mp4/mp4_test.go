@@ -0,0 +1,225 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+// memFile is in-memory io.WriteSeeker
+type memFile struct {
+	buf []byte
+	pos int64
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	end := int(f.pos) + len(p)
+	if len(f.buf) < end {
+		f.buf = append(f.buf, make([]byte, end-len(f.buf))...)
+	}
+	copy(f.buf[f.pos:], p)
+	f.pos = int64(end)
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.pos = offset
+	case io.SeekCurrent:
+		f.pos += offset
+	case io.SeekEnd:
+		f.pos = int64(len(f.buf)) + offset
+	}
+	return f.pos, nil
+}
+
+func testNAL(typ xvctype.NALUnitType, size int, seed byte) []byte {
+	nal := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(nal[0:4], uint32(size))
+	nal[4] = byte(typ) << 1
+	for i := 5; i < len(nal); i += 1 {
+		nal[i] = seed + byte(i)
+	}
+	return nal
+}
+
+const testFrameDuration = 40 * time.Millisecond
+
+// testSamples returns samples in decoding order with B-pictures: I0 P2 B1 P4 B3 ...,
+// DTS is delayed by one frame from PTS and every syncInterval samples are sync samples.
+func testSamples(n, syncInterval int) []Sample {
+	samples := make([]Sample, n)
+	for i := 0; i < n; i += 1 {
+		pts := 0
+		switch {
+		case i == 0:
+			pts = 0
+		case i%2 == 1:
+			pts = i + 1
+		default:
+			pts = i - 1
+		}
+		s := Sample{
+			DTS: time.Duration(i-1) * testFrameDuration,
+			PTS: time.Duration(pts) * testFrameDuration,
+		}
+		switch {
+		case i%syncInterval == 0:
+			s.Sync = true
+			s.NALs = [][]byte{
+				testNAL(xvctype.SegmentHeader, 16, 0),
+				testNAL(xvctype.IntraAccessPicture, 200, byte(i)),
+			}
+		case i%2 == 1:
+			s.NALs = [][]byte{testNAL(xvctype.PredictedPicture, 80, byte(i))}
+		default:
+			s.NALs = [][]byte{testNAL(xvctype.BipredictedPicture, 40, byte(i))}
+		}
+		samples[i] = s
+	}
+	return samples
+}
+
+func assertSamples(t *testing.T, expect []Sample, r *Reader) {
+	t.Helper()
+
+	for i, e := range expect {
+		s, err := r.ReadSample()
+		if err != nil {
+			t.Fatalf("sample[%d]: %+v", i, err)
+		}
+		if e.DTS != s.DTS || e.PTS != s.PTS {
+			t.Errorf("sample[%d]: expect dts=%s pts=%s actual dts=%s pts=%s", i, e.DTS, e.PTS, s.DTS, s.PTS)
+		}
+		if e.Sync != s.Sync {
+			t.Errorf("sample[%d]: expect sync %v actual %v", i, e.Sync, s.Sync)
+		}
+		if len(e.NALs) != len(s.NALs) {
+			t.Fatalf("sample[%d]: expect %d nals actual %d", i, len(e.NALs), len(s.NALs))
+		}
+		for j := range e.NALs {
+			if bytes.Equal(e.NALs[j], s.NALs[j]) != true {
+				t.Errorf("sample[%d]: nal[%d] not same", i, j)
+			}
+		}
+	}
+	if _, err := r.ReadSample(); err != io.EOF {
+		t.Errorf("expect io.EOF actual %v", err)
+	}
+}
+
+func assertSeekSync(t *testing.T, samples []Sample, r *Reader) {
+	t.Helper()
+
+	// sample[6] is the sync sample at PTS 5 frames
+	r.SeekSync(6 * testFrameDuration)
+	s, err := r.ReadSample()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if s.Sync != true || s.PTS != samples[6].PTS {
+		t.Errorf("expect sync sample pts=%s actual sync=%v pts=%s", samples[6].PTS, s.Sync, s.PTS)
+	}
+
+	// before PTS of sample[6]
+	r.SeekSync(4 * testFrameDuration)
+	s, err = r.ReadSample()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if s.Sync != true || s.PTS != samples[0].PTS {
+		t.Errorf("expect sync sample pts=%s actual sync=%v pts=%s", samples[0].PTS, s.Sync, s.PTS)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	samples := testSamples(12, 6)
+
+	f := &memFile{}
+	w, err := NewWriter(f, 320, 240)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	for _, s := range samples {
+		if err := w.WriteSample(s.DTS, s.PTS, s.NALs...); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	stbl, ok := findPath(f.buf, "moov", "trak", "mdia", "minf", "stbl")
+	if ok != true {
+		t.Fatalf("stbl not found")
+	}
+	if _, ok := findPath(stbl, "ctts"); ok != true {
+		t.Errorf("expect ctts for reordered samples")
+	}
+	if _, ok := findPath(stbl, "stss"); ok != true {
+		t.Errorf("expect stss for non-sync samples")
+	}
+
+	r, err := NewReader(bytes.NewReader(f.buf))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if r.Width() != 320 || r.Height() != 240 {
+		t.Errorf("expect 320x240 actual %dx%d", r.Width(), r.Height())
+	}
+	if r.NumSamples() != len(samples) {
+		t.Errorf("expect %d samples actual %d", len(samples), r.NumSamples())
+	}
+	if bytes.Equal(r.ConfigNAL(), samples[0].NALs[0]) != true {
+		t.Errorf("expect config nal is segment header")
+	}
+	assertSamples(t, samples, r)
+	assertSeekSync(t, samples, r)
+}
+
+func TestFragmentWriter(t *testing.T) {
+	samples := testSamples(12, 6)
+
+	buf := bytes.NewBuffer(nil)
+	w := NewFragmentWriter(buf, 320, 240, WriterFragmentDuration(100*time.Millisecond))
+	for _, s := range samples {
+		if err := w.WriteSample(s.DTS, s.PTS, s.NALs...); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	boxes, err := parseBoxes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	moof := 0
+	for _, b := range boxes {
+		if b.typ == "moof" {
+			moof += 1
+		}
+	}
+	if moof < 3 {
+		t.Errorf("expect fragments split by duration and sync sample actual %d moof", moof)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if r.NumSamples() != len(samples) {
+		t.Errorf("expect %d samples actual %d", len(samples), r.NumSamples())
+	}
+	if bytes.Equal(r.ConfigNAL(), samples[0].NALs[0]) != true {
+		t.Errorf("expect config nal is segment header")
+	}
+	assertSamples(t, samples, r)
+	assertSeekSync(t, samples, r)
+}
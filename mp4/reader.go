@@ -0,0 +1,581 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+)
+
+type sampleInfo struct {
+	offset int64
+	size   uint32
+	dts    int64
+	pts    int64
+	sync   bool
+}
+
+type trackDefaults struct {
+	duration uint32
+	size     uint32
+	flags    uint32
+}
+
+// Reader reads samples of the xvc1 track from MP4 or fragmented MP4.
+type Reader struct {
+	r             io.ReadSeeker
+	trackID       uint32
+	timescale     uint32
+	width         int
+	height        int
+	segmentHeader []byte
+	defaults      trackDefaults
+	mediaTime     int64
+	samples       []sampleInfo
+	index         int
+}
+
+func NewReader(r io.ReadSeeker) (*Reader, error) {
+	rd := &Reader{
+		r:         r,
+		timescale: DefaultTimescale,
+		samples:   make([]sampleInfo, 0, 1024),
+	}
+	if err := rd.readBoxes(); err != nil {
+		return nil, err
+	}
+	return rd, nil
+}
+
+func (r *Reader) Width() int {
+	return r.width
+}
+
+func (r *Reader) Height() int {
+	return r.height
+}
+
+func (r *Reader) NumSamples() int {
+	return len(r.samples)
+}
+
+// ConfigNAL returns the SegmentHeader NAL unit of xvcC with length prefix, ready for xvc.Decoder.Decode
+func (r *Reader) ConfigNAL() []byte {
+	if r.segmentHeader == nil {
+		return nil
+	}
+	buf := make([]byte, 4+len(r.segmentHeader))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(r.segmentHeader)))
+	copy(buf[4:], r.segmentHeader)
+	return buf
+}
+
+// ReadSample returns the next sample in decoding order, io.EOF at the end.
+func (r *Reader) ReadSample() (*Sample, error) {
+	if len(r.samples) <= r.index {
+		return nil, io.EOF
+	}
+	s := r.samples[r.index]
+	r.index += 1
+
+	if _, err := r.r.Seek(s.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, s.size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, err
+	}
+	nals, err := splitNALs(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Sample{
+		DTS:  fromTimescale(s.dts-r.mediaTime, r.timescale),
+		PTS:  fromTimescale(s.pts-r.mediaTime, r.timescale),
+		Sync: s.sync,
+		NALs: nals,
+	}, nil
+}
+
+// SeekSync positions the reader at the last sync sample whose PTS is at or before t.
+func (r *Reader) SeekSync(t time.Duration) {
+	ts := toTimescale(t, r.timescale) + r.mediaTime
+	found := 0
+	for i, s := range r.samples {
+		if s.sync && s.pts <= ts {
+			found = i
+		}
+	}
+	r.index = found
+}
+
+func (r *Reader) readBoxes() error {
+	if _, err := r.r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var moov []byte
+	type moofBox struct {
+		offset int64
+		data   []byte
+	}
+	moofs := make([]moofBox, 0, 16)
+
+	offset := int64(0)
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r.r, header[:8]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		size := uint64(binary.BigEndian.Uint32(header[0:4]))
+		typ := string(header[4:8])
+		headerSize := uint64(8)
+		if size == 1 {
+			if _, err := io.ReadFull(r.r, header[8:16]); err != nil {
+				return err
+			}
+			size = binary.BigEndian.Uint64(header[8:16])
+			headerSize = 16
+		}
+		if size == 0 {
+			break // to the end of file
+		}
+		if size < headerSize {
+			return ErrInvalidBox
+		}
+
+		switch typ {
+		case "moov", "moof":
+			data := make([]byte, size-headerSize)
+			if _, err := io.ReadFull(r.r, data); err != nil {
+				return err
+			}
+			if typ == "moov" {
+				moov = data
+			} else {
+				moofs = append(moofs, moofBox{offset, data})
+			}
+		default:
+			if _, err := r.r.Seek(int64(size-headerSize), io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+		offset += int64(size)
+	}
+
+	if moov == nil {
+		return ErrNoXVCTrack
+	}
+	if err := r.parseMoov(moov); err != nil {
+		return err
+	}
+	for _, m := range moofs {
+		if err := r.parseMoof(m.offset, m.data); err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(r.samples, func(i, j int) bool {
+		return r.samples[i].dts < r.samples[j].dts
+	})
+	return nil
+}
+
+func (r *Reader) parseMoov(data []byte) error {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return err
+	}
+	for _, b := range boxes {
+		if b.typ != "trak" {
+			continue
+		}
+		stbl, ok := findPath(b.data, "mdia", "minf", "stbl")
+		if ok != true {
+			continue
+		}
+		found, err := r.parseStsd(stbl)
+		if err != nil {
+			return err
+		}
+		if found != true {
+			continue
+		}
+
+		if tkhd, ok := findPath(b.data, "tkhd"); ok && 4 <= len(tkhd) {
+			if tkhd[0] == 1 && 24 <= len(tkhd) {
+				r.trackID = binary.BigEndian.Uint32(tkhd[20:24])
+			} else if 16 <= len(tkhd) {
+				r.trackID = binary.BigEndian.Uint32(tkhd[12:16])
+			}
+		}
+		if mdhd, ok := findPath(b.data, "mdia", "mdhd"); ok && 4 <= len(mdhd) {
+			if mdhd[0] == 1 && 24 <= len(mdhd) {
+				r.timescale = binary.BigEndian.Uint32(mdhd[20:24])
+			} else if 16 <= len(mdhd) {
+				r.timescale = binary.BigEndian.Uint32(mdhd[12:16])
+			}
+		}
+		if r.timescale == 0 {
+			return ErrInvalidBox
+		}
+		if elst, ok := findPath(b.data, "edts", "elst"); ok {
+			r.parseElst(elst)
+		}
+		r.parseTrex(data)
+		return r.parseStbl(stbl)
+	}
+	return ErrNoXVCTrack
+}
+
+// parseElst takes media_time of the first non-empty edit
+func (r *Reader) parseElst(elst []byte) {
+	if len(elst) < 8 {
+		return
+	}
+	version := elst[0]
+	entries := int(binary.BigEndian.Uint32(elst[4:8]))
+	p := 8
+	for e := 0; e < entries; e += 1 {
+		mediaTime := int64(0)
+		if version == 1 {
+			if len(elst) < p+20 {
+				return
+			}
+			mediaTime = int64(binary.BigEndian.Uint64(elst[p+8:]))
+			p += 20
+		} else {
+			if len(elst) < p+12 {
+				return
+			}
+			mediaTime = int64(int32(binary.BigEndian.Uint32(elst[p+4:])))
+			p += 12
+		}
+		if 0 <= mediaTime {
+			r.mediaTime = mediaTime
+			return
+		}
+	}
+}
+
+func (r *Reader) parseStsd(stbl []byte) (bool, error) {
+	stsd, ok := findPath(stbl, "stsd")
+	if ok != true || len(stsd) < 8 {
+		return false, nil
+	}
+	entries, err := parseBoxes(stsd[8:])
+	if err != nil {
+		return false, err
+	}
+	entry, ok := findBox(entries, SampleEntryType)
+	if ok != true {
+		return false, nil
+	}
+	if len(entry.data) < 78 {
+		return false, ErrInvalidBox
+	}
+	r.width = int(binary.BigEndian.Uint16(entry.data[24:26]))
+	r.height = int(binary.BigEndian.Uint16(entry.data[26:28]))
+
+	children, err := parseBoxes(entry.data[78:])
+	if err != nil {
+		return false, err
+	}
+	if config, ok := findBox(children, ConfigBoxType); ok && 3 <= len(config.data) {
+		size := int(binary.BigEndian.Uint16(config.data[1:3]))
+		if len(config.data) < 3+size {
+			return false, ErrInvalidBox
+		}
+		if 0 < size {
+			r.segmentHeader = config.data[3 : 3+size]
+		}
+	}
+	return true, nil
+}
+
+func (r *Reader) parseTrex(moov []byte) {
+	mvex, ok := findPath(moov, "mvex")
+	if ok != true {
+		return
+	}
+	boxes, err := parseBoxes(mvex)
+	if err != nil {
+		return
+	}
+	for _, b := range boxes {
+		if b.typ != "trex" || len(b.data) < 24 {
+			continue
+		}
+		if binary.BigEndian.Uint32(b.data[4:8]) != r.trackID {
+			continue
+		}
+		r.defaults = trackDefaults{
+			duration: binary.BigEndian.Uint32(b.data[12:16]),
+			size:     binary.BigEndian.Uint32(b.data[16:20]),
+			flags:    binary.BigEndian.Uint32(b.data[20:24]),
+		}
+	}
+}
+
+func (r *Reader) parseStbl(stbl []byte) error {
+	stsz, ok := findPath(stbl, "stsz")
+	if ok != true || len(stsz) < 12 {
+		return ErrInvalidBox
+	}
+	uniformSize := binary.BigEndian.Uint32(stsz[4:8])
+	count := int(binary.BigEndian.Uint32(stsz[8:12]))
+	if count == 0 {
+		return nil
+	}
+	if uniformSize == 0 && len(stsz) < 12+4*count {
+		return ErrInvalidBox
+	}
+	samples := make([]sampleInfo, count)
+	for i := range samples {
+		samples[i].size = uniformSize
+		if uniformSize == 0 {
+			samples[i].size = binary.BigEndian.Uint32(stsz[12+4*i:])
+		}
+		samples[i].sync = true
+	}
+
+	// stts
+	stts, ok := findPath(stbl, "stts")
+	if ok != true || len(stts) < 8 {
+		return ErrInvalidBox
+	}
+	n, dts := 0, int64(0)
+	entries := int(binary.BigEndian.Uint32(stts[4:8]))
+	for e := 0; e < entries && 16+8*e <= len(stts); e += 1 {
+		sampleCount := int(binary.BigEndian.Uint32(stts[8+8*e:]))
+		delta := int64(binary.BigEndian.Uint32(stts[12+8*e:]))
+		for k := 0; k < sampleCount && n < count; k += 1 {
+			samples[n].dts = dts
+			samples[n].pts = dts
+			dts += delta
+			n += 1
+		}
+	}
+
+	// ctts
+	if ctts, ok := findPath(stbl, "ctts"); ok && 8 <= len(ctts) {
+		n := 0
+		entries := int(binary.BigEndian.Uint32(ctts[4:8]))
+		for e := 0; e < entries && 16+8*e <= len(ctts); e += 1 {
+			sampleCount := int(binary.BigEndian.Uint32(ctts[8+8*e:]))
+			offset := int64(int32(binary.BigEndian.Uint32(ctts[12+8*e:])))
+			for k := 0; k < sampleCount && n < count; k += 1 {
+				samples[n].pts = samples[n].dts + offset
+				n += 1
+			}
+		}
+	}
+
+	// stss
+	if stss, ok := findPath(stbl, "stss"); ok && 8 <= len(stss) {
+		for i := range samples {
+			samples[i].sync = false
+		}
+		entries := int(binary.BigEndian.Uint32(stss[4:8]))
+		for e := 0; e < entries && 12+4*e <= len(stss); e += 1 {
+			number := int(binary.BigEndian.Uint32(stss[8+4*e:]))
+			if 0 < number && number <= count {
+				samples[number-1].sync = true
+			}
+		}
+	}
+
+	// stco / co64
+	chunkOffsets := make([]int64, 0, count)
+	if stco, ok := findPath(stbl, "stco"); ok && 8 <= len(stco) {
+		entries := int(binary.BigEndian.Uint32(stco[4:8]))
+		for e := 0; e < entries && 12+4*e <= len(stco); e += 1 {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint32(stco[8+4*e:])))
+		}
+	} else if co64, ok := findPath(stbl, "co64"); ok && 8 <= len(co64) {
+		entries := int(binary.BigEndian.Uint32(co64[4:8]))
+		for e := 0; e < entries && 16+8*e <= len(co64); e += 1 {
+			chunkOffsets = append(chunkOffsets, int64(binary.BigEndian.Uint64(co64[8+8*e:])))
+		}
+	}
+
+	// stsc
+	stsc, ok := findPath(stbl, "stsc")
+	if ok != true || len(stsc) < 8 {
+		return ErrInvalidBox
+	}
+	type chunkRun struct {
+		firstChunk      int
+		samplesPerChunk int
+	}
+	runs := make([]chunkRun, 0, 4)
+	entries = int(binary.BigEndian.Uint32(stsc[4:8]))
+	for e := 0; e < entries && 20+12*e <= len(stsc); e += 1 {
+		runs = append(runs, chunkRun{
+			firstChunk:      int(binary.BigEndian.Uint32(stsc[8+12*e:])),
+			samplesPerChunk: int(binary.BigEndian.Uint32(stsc[12+12*e:])),
+		})
+	}
+
+	n = 0
+	for c := 0; c < len(chunkOffsets) && n < count; c += 1 {
+		perChunk := 0
+		for _, run := range runs {
+			if run.firstChunk <= c+1 {
+				perChunk = run.samplesPerChunk
+			}
+		}
+		offset := chunkOffsets[c]
+		for k := 0; k < perChunk && n < count; k += 1 {
+			samples[n].offset = offset
+			offset += int64(samples[n].size)
+			n += 1
+		}
+	}
+
+	r.samples = append(r.samples, samples...)
+	return nil
+}
+
+func (r *Reader) parseMoof(moofOffset int64, data []byte) error {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return err
+	}
+	for _, b := range boxes {
+		if b.typ != "traf" {
+			continue
+		}
+		if err := r.parseTraf(moofOffset, b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) parseTraf(moofOffset int64, data []byte) error {
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		return err
+	}
+	tfhd, ok := findBox(boxes, "tfhd")
+	if ok != true || len(tfhd.data) < 8 {
+		return ErrInvalidBox
+	}
+	flags := binary.BigEndian.Uint32(tfhd.data[0:4]) & 0x00ffffff
+	if binary.BigEndian.Uint32(tfhd.data[4:8]) != r.trackID {
+		return nil
+	}
+
+	defaults := r.defaults
+	base := moofOffset
+	p := 8
+	readU32 := func() uint32 {
+		if len(tfhd.data) < p+4 {
+			return 0
+		}
+		v := binary.BigEndian.Uint32(tfhd.data[p:])
+		p += 4
+		return v
+	}
+	if (flags & 0x000001) != 0 {
+		if len(tfhd.data) < p+8 {
+			return ErrInvalidBox
+		}
+		base = int64(binary.BigEndian.Uint64(tfhd.data[p:]))
+		p += 8
+	}
+	if (flags & 0x000002) != 0 {
+		readU32() // sample_description_index
+	}
+	if (flags & 0x000008) != 0 {
+		defaults.duration = readU32()
+	}
+	if (flags & 0x000010) != 0 {
+		defaults.size = readU32()
+	}
+	if (flags & 0x000020) != 0 {
+		defaults.flags = readU32()
+	}
+
+	dts := int64(0)
+	if 0 < len(r.samples) {
+		last := r.samples[len(r.samples)-1]
+		dts = last.dts + int64(defaults.duration)
+	}
+	if tfdt, ok := findBox(boxes, "tfdt"); ok && 8 <= len(tfdt.data) {
+		if tfdt.data[0] == 1 && 12 <= len(tfdt.data) {
+			dts = int64(binary.BigEndian.Uint64(tfdt.data[4:12]))
+		} else {
+			dts = int64(binary.BigEndian.Uint32(tfdt.data[4:8]))
+		}
+	}
+
+	dataPos := base
+	for _, b := range boxes {
+		if b.typ != "trun" {
+			continue
+		}
+		trun := b.data
+		if len(trun) < 8 {
+			return ErrInvalidBox
+		}
+		trunFlags := binary.BigEndian.Uint32(trun[0:4]) & 0x00ffffff
+		count := int(binary.BigEndian.Uint32(trun[4:8]))
+		q := 8
+		if (trunFlags & 0x000001) != 0 {
+			if len(trun) < q+4 {
+				return ErrInvalidBox
+			}
+			dataPos = base + int64(int32(binary.BigEndian.Uint32(trun[q:])))
+			q += 4
+		}
+		firstFlags, hasFirstFlags := uint32(0), false
+		if (trunFlags & 0x000004) != 0 {
+			if len(trun) < q+4 {
+				return ErrInvalidBox
+			}
+			firstFlags, hasFirstFlags = binary.BigEndian.Uint32(trun[q:]), true
+			q += 4
+		}
+
+		for i := 0; i < count; i += 1 {
+			s := sampleInfo{}
+			duration, size, sampleFlags, cto := defaults.duration, defaults.size, defaults.flags, int64(0)
+			fields := []struct {
+				flag uint32
+				set  func(uint32)
+			}{
+				{0x000100, func(v uint32) { duration = v }},
+				{0x000200, func(v uint32) { size = v }},
+				{0x000400, func(v uint32) { sampleFlags = v }},
+				{0x000800, func(v uint32) { cto = int64(int32(v)) }},
+			}
+			for _, f := range fields {
+				if (trunFlags & f.flag) == 0 {
+					continue
+				}
+				if len(trun) < q+4 {
+					return ErrInvalidBox
+				}
+				f.set(binary.BigEndian.Uint32(trun[q:]))
+				q += 4
+			}
+			if i == 0 && hasFirstFlags {
+				sampleFlags = firstFlags
+			}
+
+			s.offset = dataPos
+			s.size = size
+			s.dts = dts
+			s.pts = dts + cto
+			s.sync = (sampleFlags & 0x00010000) == 0
+			r.samples = append(r.samples, s)
+
+			dataPos += int64(size)
+			dts += int64(duration)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package mp4
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+type sampleEntry struct {
+	dts    int64
+	pts    int64
+	size   uint32
+	offset uint64
+	sync   bool
+}
+
+// Writer writes progressive MP4, samples are written to mdat and moov is written on Close.
+type Writer struct {
+	w      io.WriteSeeker
+	opt    *writerOption
+	width  int
+	height int
+
+	mdatPos       int64
+	offset        uint64
+	samples       []sampleEntry
+	firstDTS      int64
+	segmentHeader []byte
+	closed        bool
+}
+
+func NewWriter(w io.WriteSeeker, width, height int, funcs ...writerOptionFunc) (*Writer, error) {
+	opt := defaultWriterOption()
+	for _, fn := range funcs {
+		fn(opt)
+	}
+
+	if _, err := w.Write(ftypBox()); err != nil {
+		return nil, err
+	}
+	mdatPos, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	// 64bit largesize, fixed on Close
+	header := newBoxWriter(16).u32(1).bytes([]byte("mdat")).u64(0).Bytes()
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:       w,
+		opt:     opt,
+		width:   width,
+		height:  height,
+		mdatPos: mdatPos,
+		offset:  uint64(mdatPos) + uint64(len(header)),
+		samples: make([]sampleEntry, 0, 1024),
+	}, nil
+}
+
+// WriteSample writes length prefixed NAL units of a picture (e.g. xvc.NALUnit.Bytes()) in decoding order.
+// a sample containing IntraAccessPicture is a sync sample.
+func (w *Writer) WriteSample(dts, pts time.Duration, nals ...[]byte) error {
+	if w.closed {
+		return ErrWriterClose
+	}
+	sync, segmentHeader, err := inspectNALs(nals)
+	if err != nil {
+		return err
+	}
+	if w.segmentHeader == nil && segmentHeader != nil {
+		w.segmentHeader = append([]byte(nil), segmentHeader...)
+	}
+
+	data := bytes.Join(nals, nil)
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	// decoding time starts at 0
+	if len(w.samples) < 1 {
+		w.firstDTS = toTimescale(dts, w.opt.timescale)
+	}
+	w.samples = append(w.samples, sampleEntry{
+		dts:    toTimescale(dts, w.opt.timescale) - w.firstDTS,
+		pts:    toTimescale(pts, w.opt.timescale) - w.firstDTS,
+		size:   uint32(len(data)),
+		offset: w.offset,
+		sync:   sync,
+	})
+	w.offset += uint64(len(data))
+	return nil
+}
+
+// Close fixes mdat size and writes moov.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrWriterClose
+	}
+	w.closed = true
+
+	end := w.offset
+	if _, err := w.w.Seek(w.mdatPos+8, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(newBoxWriter(8).u64(end - uint64(w.mdatPos)).Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.w.Seek(int64(end), io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := w.w.Write(w.moovBox()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) moovBox() []byte {
+	durations := sampleDurations(w.samples)
+	total := uint64(0)
+	for _, d := range durations {
+		total += uint64(d)
+	}
+
+	stbl := box("stbl",
+		stsdBox(w.width, w.height, w.segmentHeader),
+		w.sttsBox(durations),
+		w.cttsBox(),
+		w.stssBox(),
+		w.stscBox(),
+		w.stszBox(),
+		w.stcoBox(),
+	)
+	minf := box("minf", fullBox("vmhd", 0, 0x000001, newBoxWriter(8).zeros(8).Bytes()), dinfBox(), stbl)
+	mdia := box("mdia", mdhdBox(w.opt.timescale, total), hdlrBox(), minf)
+	trak := box("trak", tkhdBox(total, w.width, w.height), edtsBox(total, minPTS(w.samples)), mdia)
+	return box("moov", mvhdBox(w.opt.timescale, total), trak)
+}
+
+func minPTS(samples []sampleEntry) int64 {
+	if len(samples) < 1 {
+		return 0
+	}
+	min := samples[0].pts
+	for _, s := range samples {
+		if s.pts < min {
+			min = s.pts
+		}
+	}
+	return min
+}
+
+// sampleDurations returns the DTS delta of each sample, the last sample takes the previous delta
+func sampleDurations(samples []sampleEntry) []uint32 {
+	durations := make([]uint32, len(samples))
+	for i := 0; i+1 < len(samples); i += 1 {
+		durations[i] = uint32(samples[i+1].dts - samples[i].dts)
+	}
+	if 1 < len(samples) {
+		durations[len(samples)-1] = durations[len(samples)-2]
+	}
+	return durations
+}
+
+func (w *Writer) sttsBox(durations []uint32) []byte {
+	entries := newBoxWriter(8 * len(durations))
+	count := uint32(0)
+	for i := 0; i < len(durations); {
+		j := i
+		for j < len(durations) && durations[j] == durations[i] {
+			j += 1
+		}
+		entries.u32(uint32(j - i)).u32(durations[i])
+		count += 1
+		i = j
+	}
+	return fullBox("stts", 0, 0, newBoxWriter(4).u32(count).Bytes(), entries.Bytes())
+}
+
+// cttsBox returns composition offsets (PTS - DTS), empty when no sample is reordered
+func (w *Writer) cttsBox() []byte {
+	reordered := false
+	for _, s := range w.samples {
+		if s.pts != s.dts {
+			reordered = true
+			break
+		}
+	}
+	if reordered != true {
+		return nil
+	}
+
+	entries := newBoxWriter(8 * len(w.samples))
+	count := uint32(0)
+	for i := 0; i < len(w.samples); {
+		offset := w.samples[i].pts - w.samples[i].dts
+		j := i
+		for j < len(w.samples) && w.samples[j].pts-w.samples[j].dts == offset {
+			j += 1
+		}
+		entries.u32(uint32(j - i)).u32(uint32(int32(offset)))
+		count += 1
+		i = j
+	}
+	return fullBox("ctts", 1, 0, newBoxWriter(4).u32(count).Bytes(), entries.Bytes())
+}
+
+// stssBox returns sync sample table, empty when all samples are sync samples
+func (w *Writer) stssBox() []byte {
+	syncs := make([]uint32, 0, len(w.samples))
+	for i, s := range w.samples {
+		if s.sync {
+			syncs = append(syncs, uint32(i+1))
+		}
+	}
+	if len(syncs) == len(w.samples) {
+		return nil
+	}
+	entries := newBoxWriter(4 * len(syncs))
+	for _, n := range syncs {
+		entries.u32(n)
+	}
+	return fullBox("stss", 0, 0, newBoxWriter(4).u32(uint32(len(syncs))).Bytes(), entries.Bytes())
+}
+
+// stscBox puts each sample in its own chunk
+func (w *Writer) stscBox() []byte {
+	if len(w.samples) < 1 {
+		return fullBox("stsc", 0, 0, newBoxWriter(4).u32(0).Bytes())
+	}
+	entry := newBoxWriter(12).u32(1).u32(1).u32(1).Bytes()
+	return fullBox("stsc", 0, 0, newBoxWriter(4).u32(1).Bytes(), entry)
+}
+
+func (w *Writer) stszBox() []byte {
+	entries := newBoxWriter(4 * len(w.samples))
+	for _, s := range w.samples {
+		entries.u32(s.size)
+	}
+	return fullBox("stsz", 0, 0, newBoxWriter(8).u32(0).u32(uint32(len(w.samples))).Bytes(), entries.Bytes())
+}
+
+func (w *Writer) stcoBox() []byte {
+	large := 0 < len(w.samples) && uint64(0xffffffff) < w.samples[len(w.samples)-1].offset
+	if large {
+		entries := newBoxWriter(8 * len(w.samples))
+		for _, s := range w.samples {
+			entries.u64(s.offset)
+		}
+		return fullBox("co64", 0, 0, newBoxWriter(4).u32(uint32(len(w.samples))).Bytes(), entries.Bytes())
+	}
+	entries := newBoxWriter(4 * len(w.samples))
+	for _, s := range w.samples {
+		entries.u32(uint32(s.offset))
+	}
+	return fullBox("stco", 0, 0, newBoxWriter(4).u32(uint32(len(w.samples))).Bytes(), entries.Bytes())
+}
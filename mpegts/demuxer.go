@@ -0,0 +1,297 @@
+package mpegts
+
+import (
+	"bytes"
+	"io"
+)
+
+// Demuxer reads TS packets and reconstructs access units of the xvc stream.
+type Demuxer struct {
+	r        io.Reader
+	packet   []byte
+	pmtPID   int
+	xvcPID   int
+	lastCC   map[uint16]int
+	pes      *bytes.Buffer
+	random   bool
+	broken   bool
+	lastPTS  int64
+	lastDTS  int64
+	hasLast  bool
+	queue    []*AccessUnit
+	finished bool
+}
+
+func NewDemuxer(r io.Reader) *Demuxer {
+	return &Demuxer{
+		r:      r,
+		packet: make([]byte, PacketSize),
+		pmtPID: -1,
+		xvcPID: -1,
+		lastCC: make(map[uint16]int, 3),
+		pes:    nil,
+		queue:  make([]*AccessUnit, 0, 1),
+	}
+}
+
+// ReadAccessUnit returns the next access unit, io.EOF at the end of stream.
+// PES packets with continuity counter errors are discarded.
+func (d *Demuxer) ReadAccessUnit() (*AccessUnit, error) {
+	for len(d.queue) < 1 {
+		if d.finished {
+			return nil, io.EOF
+		}
+		if err := d.readPacket(); err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			d.finished = true
+			if err := d.completePES(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	au := d.queue[0]
+	d.queue = d.queue[1:]
+	return au, nil
+}
+
+func (d *Demuxer) readPacket() error {
+	pkt := d.packet
+	if _, err := io.ReadFull(d.r, pkt); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	if pkt[0] != syncByte {
+		return ErrInvalidPacket
+	}
+
+	unitStart := (pkt[1] & 0x40) != 0
+	pid := (uint16(pkt[1]&0x1f) << 8) | uint16(pkt[2])
+	afControl := (pkt[3] >> 4) & 0x03
+	cc := int(pkt[3] & 0x0f)
+
+	offset := packetHeaderSize
+	randomAccess := false
+	if (afControl & 0x02) != 0 {
+		afLength := int(pkt[4])
+		if packetPayloadSize <= afLength {
+			return ErrInvalidPacket
+		}
+		if 0 < afLength {
+			randomAccess = (pkt[5] & 0x40) != 0
+		}
+		offset += 1 + afLength
+	}
+	if (afControl & 0x01) == 0 {
+		return nil // no payload
+	}
+	payload := pkt[offset:]
+
+	if last, ok := d.lastCC[pid]; ok && ((last+1)&0x0f) != cc && last != cc {
+		if int(pid) == d.xvcPID {
+			d.broken = true
+		}
+	}
+	d.lastCC[pid] = cc
+
+	switch {
+	case pid == patPID:
+		if unitStart {
+			return d.parsePAT(payload)
+		}
+	case int(pid) == d.pmtPID:
+		if unitStart {
+			return d.parsePMT(payload)
+		}
+	case int(pid) == d.xvcPID:
+		if unitStart {
+			if err := d.completePES(); err != nil {
+				return err
+			}
+			d.pes = bytes.NewBuffer(make([]byte, 0, 64*1024))
+			d.random = randomAccess
+			d.broken = false
+		}
+		if d.pes != nil {
+			d.pes.Write(payload)
+		}
+	}
+	return nil
+}
+
+// section returns PSI section in payload with pointer_field
+func section(payload []byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	pointer := int(payload[0])
+	if len(payload) < 1+pointer+3 {
+		return nil
+	}
+	s := payload[1+pointer:]
+	length := int(s[1]&0x0f)<<8 | int(s[2])
+	if len(s) < 3+length || length < 9 {
+		return nil
+	}
+	s = s[:3+length]
+	if crc32MPEG2(s) != 0 {
+		return nil // CRC_32 of a valid section including CRC is 0
+	}
+	return s
+}
+
+func (d *Demuxer) parsePAT(payload []byte) error {
+	s := section(payload)
+	if s == nil || s[0] != tableIDPAT {
+		return nil
+	}
+	programs := s[8 : len(s)-4]
+	for i := 0; i+4 <= len(programs); i += 4 {
+		number := int(programs[i])<<8 | int(programs[i+1])
+		if number == 0 {
+			continue // network PID
+		}
+		d.pmtPID = int(programs[i+2]&0x1f)<<8 | int(programs[i+3])
+		return nil
+	}
+	return nil
+}
+
+func (d *Demuxer) parsePMT(payload []byte) error {
+	s := section(payload)
+	if s == nil || s[0] != tableIDPMT {
+		return nil
+	}
+	if len(s) < 16 {
+		return ErrInvalidPSI
+	}
+	programInfoLength := int(s[10]&0x0f)<<8 | int(s[11])
+	if len(s)-4 < 12+programInfoLength {
+		return ErrInvalidPSI
+	}
+	streams := s[12+programInfoLength : len(s)-4]
+	for 5 <= len(streams) {
+		streamType := streams[0]
+		pid := int(streams[1]&0x1f)<<8 | int(streams[2])
+		esInfoLength := int(streams[3]&0x0f)<<8 | int(streams[4])
+		if len(streams) < 5+esInfoLength {
+			return ErrInvalidPSI
+		}
+		descriptors := streams[5 : 5+esInfoLength]
+		if streamType == StreamTypeXVC && hasRegistration(descriptors) {
+			d.xvcPID = pid
+			return nil
+		}
+		streams = streams[5+esInfoLength:]
+	}
+	return nil
+}
+
+func hasRegistration(descriptors []byte) bool {
+	for 2 <= len(descriptors) {
+		tag, length := descriptors[0], int(descriptors[1])
+		if len(descriptors) < 2+length {
+			return false
+		}
+		if tag == registrationTag && string(descriptors[2:2+length]) == FormatIdentifier {
+			return true
+		}
+		descriptors = descriptors[2+length:]
+	}
+	return false
+}
+
+func (d *Demuxer) completePES() error {
+	if d.pes == nil {
+		return nil
+	}
+	data := d.pes.Bytes()
+	broken := d.broken
+	d.pes = nil
+	if broken {
+		return nil
+	}
+
+	if len(data) < 9 || data[0] != 0x00 || data[1] != 0x00 || data[2] != 0x01 {
+		return ErrInvalidPES
+	}
+	flags := data[7]
+	headerLength := int(data[8])
+	if len(data) < 9+headerLength {
+		return ErrInvalidPES
+	}
+	header := data[9 : 9+headerLength]
+
+	pts, dts := int64(0), int64(0)
+	switch flags & 0xc0 {
+	case 0x80:
+		if len(header) < 5 {
+			return ErrInvalidPES
+		}
+		pts = readTimestamp(header[0:5])
+		dts = pts
+	case 0xc0:
+		if len(header) < 10 {
+			return ErrInvalidPES
+		}
+		pts = readTimestamp(header[0:5])
+		dts = readTimestamp(header[5:10])
+	}
+
+	nals, err := splitNALs(data[9+headerLength:])
+	if err != nil {
+		return err
+	}
+
+	pts, dts = d.unwrap(pts, dts)
+	d.queue = append(d.queue, &AccessUnit{
+		PTS:          fromClock(pts),
+		DTS:          fromClock(dts),
+		RandomAccess: d.random,
+		NALs:         nals,
+	})
+	return nil
+}
+
+// unwrap extends 33bit timestamps to the value closest to the previous one.
+// the first timestamp is sign extended, so that timestamps near zero can be negative.
+func (d *Demuxer) unwrap(pts, dts int64) (int64, int64) {
+	if d.hasLast != true {
+		d.lastPTS, d.lastDTS = signExtend(pts), signExtend(dts)
+		d.hasLast = true
+		return d.lastPTS, d.lastDTS
+	}
+	d.lastPTS = closest(pts, d.lastPTS)
+	d.lastDTS = closest(dts, d.lastDTS)
+	return d.lastPTS, d.lastDTS
+}
+
+func signExtend(ts int64) int64 {
+	if (ts & (1 << 32)) != 0 {
+		return ts - (1 << 33)
+	}
+	return ts
+}
+
+func closest(ts int64, last int64) int64 {
+	const period int64 = 1 << 33
+	v := ts + ((last-ts)/period)*period
+	for period/2 < v-last {
+		v -= period
+	}
+	for period/2 < last-v {
+		v += period
+	}
+	return v
+}
+
+func readTimestamp(b []byte) int64 {
+	return int64(b[0]&0x0e)<<29 |
+		int64(b[1])<<22 |
+		int64(b[2]&0xfe)<<14 |
+		int64(b[3])<<7 |
+		int64(b[4]&0xfe)>>1
+}
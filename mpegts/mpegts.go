@@ -0,0 +1,118 @@
+// Package mpegts implements MPEG-TS muxer and demuxer of xvc streams.
+//
+// xvc elementary stream is carried as stream_type 0x06 (PES private data) with a registration descriptor
+// whose format_identifier is "XVC1". a PES packet holds the length prefixed NAL units of an access unit
+// (the same format as xvc.NALUnit.Bytes()), and random_access_indicator is set on access units with IntraAccessPicture.
+package mpegts
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	PacketSize int = 188
+
+	StreamTypeXVC    uint8  = 0x06
+	FormatIdentifier string = "XVC1"
+)
+
+const (
+	syncByte          byte   = 0x47
+	patPID            uint16 = 0x0000
+	pmtPID            uint16 = 0x1000
+	videoPID          uint16 = 0x0100
+	programNumber     uint16 = 1
+	videoStreamID     byte   = 0xE0
+	tableIDPAT        byte   = 0x00
+	tableIDPMT        byte   = 0x02
+	registrationTag   byte   = 0x05
+	packetHeaderSize  int    = 4
+	packetPayloadSize int    = PacketSize - packetHeaderSize
+
+	timestampClock int64 = 90000
+	timestampMask  int64 = (1 << 33) - 1
+)
+
+var (
+	ErrInvalidPacket = errors.New("mpegts: invalid packet")
+	ErrInvalidNAL    = errors.New("mpegts: invalid nal unit")
+	ErrInvalidPES    = errors.New("mpegts: invalid pes")
+	ErrInvalidPSI    = errors.New("mpegts: invalid psi section")
+)
+
+// AccessUnit is the NAL units of a picture carried in a PES packet
+type AccessUnit struct {
+	PTS          time.Duration
+	DTS          time.Duration
+	RandomAccess bool
+	// NALs are length prefixed NAL units, ready for xvc.Decoder.Decode
+	NALs [][]byte
+}
+
+func toClock(d time.Duration) int64 {
+	return int64(d) * timestampClock / int64(time.Second)
+}
+
+func fromClock(v int64) time.Duration {
+	return time.Duration(v * int64(time.Second) / timestampClock)
+}
+
+// isRandomAccess validates length prefixed NAL units and returns whether nals contain IntraAccessPicture
+func isRandomAccess(nals [][]byte) (bool, error) {
+	random := false
+	for _, nal := range nals {
+		if len(nal) < 5 || len(nal) < 4+int(binary.LittleEndian.Uint32(nal[0:4])) {
+			return false, ErrInvalidNAL
+		}
+		if xvctype.ParseNALUnitType(nal[4]) == xvctype.IntraAccessPicture {
+			random = true
+		}
+	}
+	return random, nil
+}
+
+// splitNALs splits concatenated length prefixed NAL units
+func splitNALs(data []byte) ([][]byte, error) {
+	nals := make([][]byte, 0, 4)
+	for 0 < len(data) {
+		if len(data) < 5 {
+			return nil, ErrInvalidNAL
+		}
+		size := 4 + int(binary.LittleEndian.Uint32(data[0:4]))
+		if len(data) < size {
+			return nil, ErrInvalidNAL
+		}
+		nals = append(nals, data[:size])
+		data = data[size:]
+	}
+	return nals, nil
+}
+
+var crcTable = func() [256]uint32 {
+	table := [256]uint32{}
+	for i := 0; i < 256; i += 1 {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j += 1 {
+			if (crc & 0x80000000) != 0 {
+				crc = (crc << 1) ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// crc32MPEG2 returns CRC_32 of PSI section
+func crc32MPEG2(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc = (crc << 8) ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
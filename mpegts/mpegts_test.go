@@ -0,0 +1,179 @@
+package mpegts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+func testNAL(typ xvctype.NALUnitType, size int, seed byte) []byte {
+	nal := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(nal[0:4], uint32(size))
+	nal[4] = byte(typ) << 1
+	for i := 5; i < len(nal); i += 1 {
+		nal[i] = seed + byte(i)
+	}
+	return nal
+}
+
+// testAccessUnits returns access units in decoding order with B-pictures: I0 P2 B1 P4 B3 ...
+// DTS of the first access unit is negative.
+func testAccessUnits(n, keyInterval int) []AccessUnit {
+	d := 40 * time.Millisecond
+	units := make([]AccessUnit, n)
+	for i := 0; i < n; i += 1 {
+		pts := 0
+		switch {
+		case i == 0:
+			pts = 0
+		case i%2 == 1:
+			pts = i + 1
+		default:
+			pts = i - 1
+		}
+		au := AccessUnit{
+			PTS: time.Duration(pts) * d,
+			DTS: time.Duration(i-1) * d,
+		}
+		if i%keyInterval == 0 {
+			au.RandomAccess = true
+			au.NALs = [][]byte{
+				testNAL(xvctype.SegmentHeader, 16, 0),
+				testNAL(xvctype.IntraAccessPicture, 1000, byte(i)),
+			}
+		} else {
+			au.NALs = [][]byte{
+				testNAL(xvctype.PredictedPicture, 300, byte(i)),
+			}
+		}
+		units[i] = au
+	}
+	return units
+}
+
+// pcrs returns PCR base and the DTS of the PES packet started by the same TS packet
+func pcrs(t *testing.T, data []byte) ([]int64, []int64) {
+	t.Helper()
+
+	pcr := make([]int64, 0, 16)
+	dts := make([]int64, 0, 16)
+	for 0 < len(data) {
+		pkt := data[:PacketSize]
+		data = data[PacketSize:]
+
+		pid := (uint16(pkt[1]&0x1f) << 8) | uint16(pkt[2])
+		if pid != videoPID || (pkt[3]&0x20) == 0 || pkt[4] == 0 || (pkt[5]&0x10) == 0 {
+			continue
+		}
+		base := int64(pkt[6])<<25 | int64(pkt[7])<<17 | int64(pkt[8])<<9 | int64(pkt[9])<<1 | int64(pkt[10])>>7
+		pes := pkt[packetHeaderSize+1+int(pkt[4]):]
+		if (pkt[1] & 0x40) == 0 {
+			t.Fatalf("expect PCR on the first packet of PES")
+		}
+		if (pes[7] & 0x40) != 0 {
+			dts = append(dts, readTimestamp(pes[14:19]))
+		} else {
+			dts = append(dts, readTimestamp(pes[9:14]))
+		}
+		pcr = append(pcr, base)
+	}
+	return pcr, dts
+}
+
+func TestRoundtrip(t *testing.T) {
+	units := testAccessUnits(20, 8)
+
+	buf := bytes.NewBuffer(nil)
+	m := NewMuxer(buf, MuxerPCRDelay(500*time.Millisecond))
+	for _, au := range units {
+		if err := m.WriteAccessUnit(au.PTS, au.DTS, au.NALs...); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	if buf.Len()%PacketSize != 0 {
+		t.Fatalf("expect multiple of %d bytes actual %d", PacketSize, buf.Len())
+	}
+
+	t.Run("pcr", func(tt *testing.T) {
+		pcr, dts := pcrs(tt, buf.Bytes())
+		if len(pcr) < 1 {
+			tt.Fatalf("expect PCR")
+		}
+		for i := range pcr {
+			diff := (dts[i] - pcr[i]) & timestampMask
+			if diff != toClock(500*time.Millisecond) {
+				tt.Errorf("pcr[%d]: expect PCR precedes DTS by 500ms actual %s", i, fromClock(diff))
+			}
+		}
+	})
+	t.Run("demux", func(tt *testing.T) {
+		d := NewDemuxer(bytes.NewReader(buf.Bytes()))
+		for i, expect := range units {
+			au, err := d.ReadAccessUnit()
+			if err != nil {
+				tt.Fatalf("au[%d]: %+v", i, err)
+			}
+			if expect.PTS != au.PTS || expect.DTS != au.DTS {
+				tt.Errorf("au[%d]: expect pts=%s dts=%s actual pts=%s dts=%s", i, expect.PTS, expect.DTS, au.PTS, au.DTS)
+			}
+			if expect.RandomAccess != au.RandomAccess {
+				tt.Errorf("au[%d]: expect random access %v actual %v", i, expect.RandomAccess, au.RandomAccess)
+			}
+			if len(expect.NALs) != len(au.NALs) {
+				tt.Fatalf("au[%d]: expect %d nals actual %d", i, len(expect.NALs), len(au.NALs))
+			}
+			for j := range expect.NALs {
+				if bytes.Equal(expect.NALs[j], au.NALs[j]) != true {
+					tt.Errorf("au[%d]: nal[%d] not same", i, j)
+				}
+			}
+		}
+		if _, err := d.ReadAccessUnit(); err != io.EOF {
+			tt.Errorf("expect io.EOF actual %v", err)
+		}
+	})
+}
+
+func TestParsePMT(t *testing.T) {
+	t.Run("valid", func(tt *testing.T) {
+		d := NewDemuxer(nil)
+		if err := d.parsePMT(append([]byte{0x00}, pmtSection()...)); err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if d.xvcPID != int(videoPID) {
+			tt.Errorf("expect xvc pid %d actual %d", videoPID, d.xvcPID)
+		}
+	})
+	t.Run("program_info_length", func(tt *testing.T) {
+		body := []byte{
+			0xe0 | byte(videoPID>>8), byte(videoPID & 0xff),
+			0xff, 0xff, // program_info_length exceeds the section
+			StreamTypeXVC,
+			0xe0 | byte(videoPID>>8), byte(videoPID & 0xff),
+			0xf0, 0x00,
+		}
+		d := NewDemuxer(nil)
+		err := d.parsePMT(append([]byte{0x00}, psiSection(tableIDPMT, programNumber, body)...))
+		if err != ErrInvalidPSI {
+			tt.Errorf("expect ErrInvalidPSI actual %v", err)
+		}
+	})
+	t.Run("es_info_length", func(tt *testing.T) {
+		body := []byte{
+			0xe0 | byte(videoPID>>8), byte(videoPID & 0xff),
+			0xf0, 0x00,
+			StreamTypeXVC,
+			0xe0 | byte(videoPID>>8), byte(videoPID & 0xff),
+			0xf0, 0x20, // es_info_length exceeds the section
+		}
+		d := NewDemuxer(nil)
+		err := d.parsePMT(append([]byte{0x00}, psiSection(tableIDPMT, programNumber, body)...))
+		if err != ErrInvalidPSI {
+			tt.Errorf("expect ErrInvalidPSI actual %v", err)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package mpegts
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+const (
+	defaultPCRInterval time.Duration = 40 * time.Millisecond
+	defaultPSIInterval time.Duration = 100 * time.Millisecond
+	defaultPCRDelay    time.Duration = 700 * time.Millisecond
+)
+
+type muxerOptionFunc func(*muxerOption)
+type muxerOption struct {
+	pcrInterval time.Duration
+	psiInterval time.Duration
+	pcrDelay    time.Duration
+}
+
+// MuxerPCRInterval sets the maximum interval of PCR
+func MuxerPCRInterval(d time.Duration) muxerOptionFunc {
+	return func(o *muxerOption) {
+		o.pcrInterval = d
+	}
+}
+
+// MuxerPSIInterval sets the maximum interval of PAT/PMT, PAT/PMT are also written before random access points
+func MuxerPSIInterval(d time.Duration) muxerOptionFunc {
+	return func(o *muxerOption) {
+		o.psiInterval = d
+	}
+}
+
+// MuxerPCRDelay sets how far PCR precedes DTS, the time an access unit stays in the decoder buffer (T-STD)
+func MuxerPCRDelay(d time.Duration) muxerOptionFunc {
+	return func(o *muxerOption) {
+		o.pcrDelay = d
+	}
+}
+
+type adaptationField struct {
+	randomAccess bool
+	hasPCR       bool
+	pcr          int64 // 90kHz base
+}
+
+func (af *adaptationField) size() int {
+	if af == nil {
+		return 0
+	}
+	size := 2 // adaptation_field_length + flags
+	if af.hasPCR {
+		size += 6
+	}
+	return size
+}
+
+type Muxer struct {
+	w          io.Writer
+	opt        *muxerOption
+	cc         map[uint16]uint8
+	psiWritten bool
+	lastPSI    int64
+	pcrWritten bool
+	lastPCR    int64
+	packet     []byte
+}
+
+func NewMuxer(w io.Writer, funcs ...muxerOptionFunc) *Muxer {
+	opt := &muxerOption{
+		pcrInterval: defaultPCRInterval,
+		psiInterval: defaultPSIInterval,
+		pcrDelay:    defaultPCRDelay,
+	}
+	for _, fn := range funcs {
+		fn(opt)
+	}
+	return &Muxer{
+		w:      w,
+		opt:    opt,
+		cc:     make(map[uint16]uint8, 3),
+		packet: make([]byte, PacketSize),
+	}
+}
+
+// WriteAccessUnit writes length prefixed NAL units of a picture (e.g. xvc.NALUnit.Bytes()) as a PES packet.
+// access units must be written in decoding order.
+func (m *Muxer) WriteAccessUnit(pts, dts time.Duration, nals ...[]byte) error {
+	random, err := isRandomAccess(nals)
+	if err != nil {
+		return err
+	}
+	ptsClock, dtsClock := toClock(pts), toClock(dts)
+
+	if m.psiWritten != true || random || toClock(m.opt.psiInterval) <= dtsClock-m.lastPSI {
+		if err := m.writePSI(); err != nil {
+			return err
+		}
+		m.psiWritten = true
+		m.lastPSI = dtsClock
+	}
+
+	pes := pesPacket(ptsClock, dtsClock, bytes.Join(nals, nil))
+
+	af := &adaptationField{randomAccess: random}
+	if m.pcrWritten != true || random || toClock(m.opt.pcrInterval) <= dtsClock-m.lastPCR {
+		// PCR precedes DTS by the buffering delay, wraps around 33 bits when DTS is near zero
+		af.hasPCR = true
+		af.pcr = dtsClock - toClock(m.opt.pcrDelay)
+		m.pcrWritten = true
+		m.lastPCR = dtsClock
+	}
+	if af.randomAccess != true && af.hasPCR != true {
+		af = nil
+	}
+
+	first := true
+	for 0 < len(pes) {
+		space := packetPayloadSize - af.size()
+		n := len(pes)
+		if space < n {
+			n = space
+		}
+		if err := m.writePacket(videoPID, first, af, pes[:n]); err != nil {
+			return err
+		}
+		pes = pes[n:]
+		first = false
+		af = nil
+	}
+	return nil
+}
+
+func (m *Muxer) writePSI() error {
+	if err := m.writeSection(patPID, patSection()); err != nil {
+		return err
+	}
+	if err := m.writeSection(pmtPID, pmtSection()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Muxer) writeSection(pid uint16, section []byte) error {
+	// pointer_field
+	payload := append([]byte{0x00}, section...)
+	return m.writePacket(pid, true, nil, payload)
+}
+
+// writePacket writes a TS packet, the space not filled by payload is stuffed.
+// PSI is stuffed with 0xff after section, PES is stuffed by adaptation field.
+func (m *Muxer) writePacket(pid uint16, unitStart bool, af *adaptationField, payload []byte) error {
+	pkt := m.packet
+	for i := range pkt {
+		pkt[i] = 0xff
+	}
+
+	pkt[0] = syncByte
+	pkt[1] = byte(pid>>8) & 0x1f
+	if unitStart {
+		pkt[1] |= 0x40
+	}
+	pkt[2] = byte(pid & 0xff)
+
+	// total size of adaptation field including adaptation_field_length
+	afSize := af.size()
+	isPSI := pid == patPID || pid == pmtPID
+	if isPSI != true {
+		afSize = packetPayloadSize - len(payload)
+	}
+
+	if 0 < afSize {
+		pkt[3] = 0x30 | m.nextCC(pid) // adaptation field and payload
+		pkt[4] = byte(afSize - 1)
+		if 1 < afSize {
+			pkt[5] = 0x00
+			if af != nil && af.randomAccess {
+				pkt[5] |= 0x40
+			}
+			if af != nil && af.hasPCR {
+				pkt[5] |= 0x10
+				base := af.pcr & timestampMask
+				pkt[6] = byte(base >> 25)
+				pkt[7] = byte(base >> 17)
+				pkt[8] = byte(base >> 9)
+				pkt[9] = byte(base >> 1)
+				pkt[10] = byte(base<<7) | 0x7e // reserved, extension = 0
+				pkt[11] = 0x00
+			}
+			// stuffing bytes are 0xff already
+		}
+	} else {
+		pkt[3] = 0x10 | m.nextCC(pid) // payload only
+	}
+	copy(pkt[packetHeaderSize+afSize:], payload)
+
+	_, err := m.w.Write(pkt)
+	return err
+}
+
+func (m *Muxer) nextCC(pid uint16) byte {
+	cc := m.cc[pid]
+	m.cc[pid] = (cc + 1) & 0x0f
+	return cc
+}
+
+func pesPacket(pts, dts int64, data []byte) []byte {
+	hasDTS := pts != dts
+	headerLength := 5
+	flags := byte(0x80) // PTS only
+	if hasDTS {
+		headerLength = 10
+		flags = 0xc0
+	}
+
+	buf := make([]byte, 0, 9+headerLength+len(data))
+	buf = append(buf, 0x00, 0x00, 0x01, videoStreamID)
+	buf = append(buf, 0x00, 0x00) // PES_packet_length = 0, unbounded for video
+	buf = append(buf, 0x80, flags, byte(headerLength))
+	if hasDTS {
+		buf = appendTimestamp(buf, 0x3, pts)
+		buf = appendTimestamp(buf, 0x1, dts)
+	} else {
+		buf = appendTimestamp(buf, 0x2, pts)
+	}
+	return append(buf, data...)
+}
+
+func appendTimestamp(buf []byte, prefix byte, ts int64) []byte {
+	ts &= timestampMask
+	return append(buf,
+		(prefix<<4)|byte(ts>>29)&0x0e|0x01,
+		byte(ts>>22),
+		byte(ts>>14)|0x01,
+		byte(ts>>7),
+		byte(ts<<1)|0x01,
+	)
+}
+
+func patSection() []byte {
+	body := []byte{
+		byte(programNumber >> 8), byte(programNumber & 0xff),
+		0xe0 | byte(pmtPID>>8), byte(pmtPID & 0xff),
+	}
+	return psiSection(tableIDPAT, 1, body)
+}
+
+func pmtSection() []byte {
+	descriptor := append([]byte{registrationTag, byte(len(FormatIdentifier))}, FormatIdentifier...)
+	body := []byte{
+		0xe0 | byte(videoPID>>8), byte(videoPID & 0xff), // PCR_PID
+		0xf0, 0x00, // program_info_length
+		StreamTypeXVC,
+		0xe0 | byte(videoPID>>8), byte(videoPID & 0xff),
+		0xf0 | byte(len(descriptor)>>8), byte(len(descriptor)),
+	}
+	body = append(body, descriptor...)
+	return psiSection(tableIDPMT, programNumber, body)
+}
+
+func psiSection(tableID byte, tableIDExtension uint16, body []byte) []byte {
+	sectionLength := 5 + len(body) + 4
+	section := make([]byte, 0, 3+sectionLength)
+	section = append(section,
+		tableID,
+		0xb0|byte(sectionLength>>8), byte(sectionLength),
+		byte(tableIDExtension>>8), byte(tableIDExtension),
+		0xc1,       // version 0, current_next_indicator 1
+		0x00, 0x00, // section_number, last_section_number
+	)
+	section = append(section, body...)
+	crc := crc32MPEG2(section)
+	return append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
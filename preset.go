@@ -0,0 +1,81 @@
+package xvc
+
+const (
+	PresetRealtimeConferencing string = "realtime-conferencing"
+	PresetScreenShare          string = "screen-share"
+	PresetVODHighQuality       string = "vod-high-quality"
+	PresetArchival             string = "archival"
+	PresetThumbnail            string = "thumbnail"
+)
+
+type encoderPreset struct {
+	speedMode    int // 0: Placebo, 1: Slow, 2: Fast
+	tuneMode     int // 0: Visual quality, 1: PSNR
+	lowDelay     int // 0: off, 1: on
+	qp           int
+	subGOPLength int
+	threads      int // -1: auto-detect,  0: disabled, 1+: number of threads
+}
+
+var encoderPresets = map[string]encoderPreset{
+	PresetRealtimeConferencing: {
+		speedMode:    2,
+		tuneMode:     0,
+		lowDelay:     1,
+		qp:           32,
+		subGOPLength: 1,
+		threads:      -1,
+	},
+	PresetScreenShare: {
+		speedMode:    2,
+		tuneMode:     1,
+		lowDelay:     1,
+		qp:           27,
+		subGOPLength: 1,
+		threads:      -1,
+	},
+	PresetVODHighQuality: {
+		speedMode:    1,
+		tuneMode:     0,
+		lowDelay:     0,
+		qp:           27,
+		subGOPLength: 16,
+		threads:      -1,
+	},
+	PresetArchival: {
+		speedMode:    0,
+		tuneMode:     1,
+		lowDelay:     0,
+		qp:           22,
+		subGOPLength: 16,
+		threads:      -1,
+	},
+	PresetThumbnail: {
+		speedMode:    2,
+		tuneMode:     0,
+		lowDelay:     1,
+		qp:           37,
+		subGOPLength: 1,
+		threads:      1,
+	},
+}
+
+// EncoderPreset applies the named preset.
+// options after EncoderPreset override the values of the preset, and the last EncoderPreset wins:
+// CreateEncoder returns EncNoSuchPreset if the name of the last EncoderPreset is unknown.
+func EncoderPreset(name string) encoderParameterFunc {
+	return func(p *encoderParameter) {
+		preset, ok := encoderPresets[name]
+		if ok != true {
+			p.err = EncReturnCode(EncNoSuchPreset)
+			return
+		}
+		p.err = nil
+		p.speedMode = preset.speedMode
+		p.tuneMode = preset.tuneMode
+		p.lowDelay = preset.lowDelay
+		p.qp = preset.qp
+		p.threads = preset.threads
+		p.explicitSettings.SubGOPLength = preset.subGOPLength
+	}
+}
@@ -0,0 +1,58 @@
+package xvc
+
+import (
+	"testing"
+)
+
+func TestEncoderPreset(t *testing.T) {
+	t.Run("values", func(tt *testing.T) {
+		for name, preset := range encoderPresets {
+			p := applyEncoderParameter(EncoderPreset(name))
+			if p.err != nil {
+				tt.Errorf("%s: %+v", name, p.err)
+			}
+			if p.speedMode != preset.speedMode || p.tuneMode != preset.tuneMode || p.lowDelay != preset.lowDelay {
+				tt.Errorf("%s: expect speed=%d tune=%d low_delay=%d actual speed=%d tune=%d low_delay=%d",
+					name, preset.speedMode, preset.tuneMode, preset.lowDelay, p.speedMode, p.tuneMode, p.lowDelay)
+			}
+			if p.qp != preset.qp || p.threads != preset.threads {
+				tt.Errorf("%s: expect qp=%d threads=%d actual qp=%d threads=%d", name, preset.qp, preset.threads, p.qp, p.threads)
+			}
+			if p.explicitSettings.SubGOPLength != preset.subGOPLength {
+				tt.Errorf("%s: expect sub_gop_length=%d actual %d", name, preset.subGOPLength, p.explicitSettings.SubGOPLength)
+			}
+		}
+	})
+	t.Run("override", func(tt *testing.T) {
+		p := applyEncoderParameter(EncoderPreset(PresetArchival), EncoderParameterQP(30))
+		if p.qp != 30 {
+			tt.Errorf("expect qp overridden by the following option actual %d", p.qp)
+		}
+	})
+	t.Run("last wins", func(tt *testing.T) {
+		tests := []struct {
+			name   string
+			names  []string
+			expect error
+		}{
+			{"unknown", []string{"no-such-preset"}, EncReturnCode(EncNoSuchPreset)},
+			{"unknown then valid", []string{"no-such-preset", PresetThumbnail}, nil},
+			{"valid then unknown", []string{PresetThumbnail, "no-such-preset"}, EncReturnCode(EncNoSuchPreset)},
+			{"valid then valid", []string{PresetThumbnail, PresetArchival}, nil},
+		}
+		for _, tc := range tests {
+			funcs := make([]encoderParameterFunc, len(tc.names))
+			for i, name := range tc.names {
+				funcs[i] = EncoderPreset(name)
+			}
+			p := applyEncoderParameter(funcs...)
+			if p.err != tc.expect {
+				tt.Errorf("%s: expect %v actual %v", tc.name, tc.expect, p.err)
+			}
+		}
+		p := applyEncoderParameter(EncoderPreset(PresetThumbnail), EncoderPreset(PresetArchival))
+		if p.qp != encoderPresets[PresetArchival].qp {
+			tt.Errorf("expect values of the last preset actual qp=%d", p.qp)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package quality
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrInsufficientPoints = errors.New("quality: bd-rate requires at least 2 rate-distortion points")
+	ErrNoOverlap          = errors.New("quality: quality ranges of rate-distortion curves do not overlap")
+)
+
+// RDPoint is a rate-distortion point, Rate in any unit (e.g. kbps) and Quality in dB (e.g. PSNR)
+type RDPoint struct {
+	Rate    float64
+	Quality float64
+}
+
+// BDRate computes the Bjøntegaard delta rate (VCEG-M33) of test against ref in percent,
+// negative values mean test requires less rate for the same quality.
+// log(rate) is fitted as a polynomial of quality (cubic for 4 or more points).
+func BDRate(ref, test []RDPoint) (float64, error) {
+	if len(ref) < 2 || len(test) < 2 {
+		return 0, ErrInsufficientPoints
+	}
+	refMin, refMax := qualityRange(ref)
+	testMin, testMax := qualityRange(test)
+	lo, hi := math.Max(refMin, testMin), math.Min(refMax, testMax)
+	if hi <= lo {
+		return 0, ErrNoOverlap
+	}
+
+	refPoly, err := fitLogRate(ref)
+	if err != nil {
+		return 0, err
+	}
+	testPoly, err := fitLogRate(test)
+	if err != nil {
+		return 0, err
+	}
+
+	refArea := integrate(refPoly, hi) - integrate(refPoly, lo)
+	testArea := integrate(testPoly, hi) - integrate(testPoly, lo)
+	avgDiff := (testArea - refArea) / (hi - lo)
+	return (math.Exp(avgDiff) - 1.0) * 100.0, nil
+}
+
+func qualityRange(points []RDPoint) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo = math.Min(lo, p.Quality)
+		hi = math.Max(hi, p.Quality)
+	}
+	return lo, hi
+}
+
+func fitLogRate(points []RDPoint) ([]float64, error) {
+	x := make([]float64, len(points))
+	y := make([]float64, len(points))
+	for i, p := range points {
+		if p.Rate <= 0 {
+			return nil, ErrInsufficientPoints
+		}
+		x[i] = p.Quality
+		y[i] = math.Log(p.Rate)
+	}
+	degree := len(points) - 1
+	if 3 < degree {
+		degree = 3
+	}
+	return polyfit(x, y, degree)
+}
+
+// polyfit returns least squares polynomial coefficients c[0] + c[1]x + c[2]x^2 ...
+func polyfit(x, y []float64, degree int) ([]float64, error) {
+	n := degree + 1
+	// normal equations, augmented matrix
+	m := make([][]float64, n)
+	for i := 0; i < n; i += 1 {
+		m[i] = make([]float64, n+1)
+		for j := 0; j < n; j += 1 {
+			for k := range x {
+				m[i][j] += math.Pow(x[k], float64(i+j))
+			}
+		}
+		for k := range x {
+			m[i][n] += y[k] * math.Pow(x[k], float64(i))
+		}
+	}
+
+	// gaussian elimination with partial pivoting
+	for col := 0; col < n; col += 1 {
+		pivot := col
+		for r := col + 1; r < n; r += 1 {
+			if math.Abs(m[pivot][col]) < math.Abs(m[r][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, ErrInsufficientPoints // duplicate quality values
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for r := col + 1; r < n; r += 1 {
+			f := m[r][col] / m[col][col]
+			for c := col; c <= n; c += 1 {
+				m[r][c] -= f * m[col][c]
+			}
+		}
+	}
+	coef := make([]float64, n)
+	for r := n - 1; 0 <= r; r -= 1 {
+		v := m[r][n]
+		for c := r + 1; c < n; c += 1 {
+			v -= m[r][c] * coef[c]
+		}
+		coef[r] = v / m[r][r]
+	}
+	return coef, nil
+}
+
+// integrate returns the antiderivative of polynomial coef at x
+func integrate(coef []float64, x float64) float64 {
+	v := 0.0
+	for i, c := range coef {
+		v += c * math.Pow(x, float64(i+1)) / float64(i+1)
+	}
+	return v
+}
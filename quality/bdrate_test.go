@@ -0,0 +1,58 @@
+package quality
+
+import (
+	"math"
+	"testing"
+)
+
+func testCurve(scale float64) []RDPoint {
+	// log rate is linear in quality
+	points := make([]RDPoint, 4)
+	for i := range points {
+		q := 30.0 + float64(i)*3.0
+		points[i] = RDPoint{
+			Rate:    scale * 100.0 * math.Pow(2, float64(i)),
+			Quality: q,
+		}
+	}
+	return points
+}
+
+func TestBDRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		scale  float64
+		expect float64
+	}{
+		{"same", 1.0, 0.0},
+		{"10% less rate", 0.9, -10.0},
+		{"20% more rate", 1.2, 20.0},
+	}
+	ref := testCurve(1.0)
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			rate, err := BDRate(ref, testCurve(tc.scale))
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			if math.Abs(rate-tc.expect) > 1e-6 {
+				tt.Errorf("expect %f actual %f", tc.expect, rate)
+			}
+		})
+	}
+}
+
+func TestBDRateError(t *testing.T) {
+	ref := testCurve(1.0)
+	if _, err := BDRate(ref, ref[:1]); err != ErrInsufficientPoints {
+		t.Errorf("expect ErrInsufficientPoints actual %v", err)
+	}
+
+	high := testCurve(1.0)
+	for i := range high {
+		high[i].Quality += 20.0
+	}
+	if _, err := BDRate(ref, high); err != ErrNoOverlap {
+		t.Errorf("expect ErrNoOverlap actual %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package quality computes objective quality metrics (PSNR, SSIM, MS-SSIM)
+// between source frames and decoded pictures, e.g. xvc.DecodedPicture.Image().
+//
+// planes are compared individually, 8bit images (*image.YCbCr, *image.Gray)
+// and 16bit images (*YCbCr16, *image.Gray16) are supported.
+// SSIM uses the 11x11 Gaussian window (sigma 1.5) of Wang et al. 2004.
+package quality
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// MaxPSNR is the PSNR of identical planes
+	MaxPSNR float64 = 100.0
+)
+
+var (
+	ErrUnsupportedImage = errors.New("quality: unsupported image")
+	ErrMismatch         = errors.New("quality: image size or subsample ratio mismatch")
+	ErrBitDepthMismatch = errors.New("quality: bit depth mismatch")
+)
+
+// YCbCr16 is a planar YCbCr image of 9-16bit samples, layout is the same as image.YCbCr
+// (Y[0], Cb[0] and Cr[0] are the samples at Rect.Min).
+type YCbCr16 struct {
+	Y, Cb, Cr      []uint16
+	YStride        int
+	CStride        int
+	SubsampleRatio image.YCbCrSubsampleRatio
+	Rect           image.Rectangle
+	BitDepth       int
+}
+
+func (p *YCbCr16) ColorModel() color.Model {
+	return color.YCbCrModel
+}
+
+func (p *YCbCr16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At returns the sample scaled to 8bit
+func (p *YCbCr16) At(x, y int) color.Color {
+	if (image.Point{x, y}.In(p.Rect)) != true {
+		return color.YCbCr{}
+	}
+	yi, ci := p.YOffset(x, y), p.COffset(x, y)
+	shift := uint(p.BitDepth - 8)
+	return color.YCbCr{
+		Y:  uint8(p.Y[yi] >> shift),
+		Cb: uint8(p.Cb[ci] >> shift),
+		Cr: uint8(p.Cr[ci] >> shift),
+	}
+}
+
+func (p *YCbCr16) YOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.YStride + (x - p.Rect.Min.X)
+}
+
+func (p *YCbCr16) COffset(x, y int) int {
+	dx, dy := subsampleFactor(p.SubsampleRatio)
+	return (y/dy-p.Rect.Min.Y/dy)*p.CStride + (x/dx - p.Rect.Min.X/dx)
+}
+
+// PlaneResult is the metrics of a plane
+type PlaneResult struct {
+	MSE    float64
+	PSNR   float64
+	SSIM   float64
+	MSSSIM float64
+}
+
+// Result is the metrics of a frame, Planes are Y, Cb, Cr (Y only for gray images)
+type Result struct {
+	Planes []PlaneResult
+}
+
+func (r Result) Y() PlaneResult {
+	return r.Planes[0]
+}
+
+// PSNR returns the PSNR of the frame weighted Y:Cb:Cr = 6:1:1
+func (r Result) PSNR() float64 {
+	return r.weighted(func(p PlaneResult) float64 { return p.PSNR })
+}
+
+// SSIM returns the SSIM of the frame weighted Y:Cb:Cr = 6:1:1
+func (r Result) SSIM() float64 {
+	return r.weighted(func(p PlaneResult) float64 { return p.SSIM })
+}
+
+func (r Result) weighted(fn func(PlaneResult) float64) float64 {
+	if len(r.Planes) < 3 {
+		return fn(r.Planes[0])
+	}
+	return ((6.0 * fn(r.Planes[0])) + fn(r.Planes[1]) + fn(r.Planes[2])) / 8.0
+}
+
+// plane is samples converted to float64, rows are contiguous
+type plane struct {
+	width  int
+	height int
+	pix    []float64
+}
+
+// Compare computes metrics between ref and dist of the same type, size and bit depth.
+func Compare(ref, dist image.Image) (Result, error) {
+	refPlanes, peak, err := planes(ref)
+	if err != nil {
+		return Result{}, err
+	}
+	distPlanes, distPeak, err := planes(dist)
+	if err != nil {
+		return Result{}, err
+	}
+	if peak != distPeak {
+		return Result{}, ErrBitDepthMismatch
+	}
+	if len(refPlanes) != len(distPlanes) {
+		return Result{}, ErrMismatch
+	}
+
+	result := Result{Planes: make([]PlaneResult, len(refPlanes))}
+	for i := 0; i < len(refPlanes); i += 1 {
+		r, err := comparePlane(refPlanes[i], distPlanes[i], peak)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Planes[i] = r
+	}
+	return result, nil
+}
+
+func comparePlane(a, b plane, peak float64) (PlaneResult, error) {
+	if a.width != b.width || a.height != b.height {
+		return PlaneResult{}, ErrMismatch
+	}
+	mse := meanSquaredError(a.pix, b.pix)
+	return PlaneResult{
+		MSE:    mse,
+		PSNR:   psnr(mse, peak),
+		SSIM:   ssim(a, b, peak),
+		MSSSIM: msssim(a, b, peak),
+	}, nil
+}
+
+func meanSquaredError(a, b []float64) float64 {
+	if len(a) < 1 {
+		return 0
+	}
+	b = b[:len(a)]
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum / float64(len(a))
+}
+
+func psnr(mse, peak float64) float64 {
+	if mse <= 0 {
+		return MaxPSNR
+	}
+	return math.Min(MaxPSNR, 10.0*math.Log10((peak*peak)/mse))
+}
+
+func planes(img image.Image) ([]plane, float64, error) {
+	switch v := img.(type) {
+	case *image.YCbCr:
+		cw, ch := chromaSize(v.Rect, v.SubsampleRatio)
+		w, h := v.Rect.Dx(), v.Rect.Dy()
+		return []plane{
+			plane8(v.Y[v.YOffset(v.Rect.Min.X, v.Rect.Min.Y):], v.YStride, w, h),
+			plane8(v.Cb[v.COffset(v.Rect.Min.X, v.Rect.Min.Y):], v.CStride, cw, ch),
+			plane8(v.Cr[v.COffset(v.Rect.Min.X, v.Rect.Min.Y):], v.CStride, cw, ch),
+		}, 255.0, nil
+	case *image.Gray:
+		return []plane{
+			plane8(v.Pix[v.PixOffset(v.Rect.Min.X, v.Rect.Min.Y):], v.Stride, v.Rect.Dx(), v.Rect.Dy()),
+		}, 255.0, nil
+	case *YCbCr16:
+		cw, ch := chromaSize(v.Rect, v.SubsampleRatio)
+		w, h := v.Rect.Dx(), v.Rect.Dy()
+		return []plane{
+			plane16(v.Y, v.YStride, w, h),
+			plane16(v.Cb, v.CStride, cw, ch),
+			plane16(v.Cr, v.CStride, cw, ch),
+		}, float64(int(1)<<v.BitDepth - 1), nil
+	case *image.Gray16:
+		// Gray16 is big endian 16bit samples
+		w, h := v.Rect.Dx(), v.Rect.Dy()
+		p := plane{width: w, height: h, pix: make([]float64, w*h)}
+		for y := 0; y < h; y += 1 {
+			row := v.Pix[v.PixOffset(v.Rect.Min.X, v.Rect.Min.Y+y):]
+			dst := p.pix[y*w : (y+1)*w]
+			for x := range dst {
+				dst[x] = float64(uint16(row[2*x])<<8 | uint16(row[2*x+1]))
+			}
+		}
+		return []plane{p}, 65535.0, nil
+	}
+	return nil, 0, ErrUnsupportedImage
+}
+
+func plane8(pix []uint8, stride, width, height int) plane {
+	p := plane{width: width, height: height, pix: make([]float64, width*height)}
+	for y := 0; y < height; y += 1 {
+		src := pix[y*stride : y*stride+width]
+		dst := p.pix[y*width : (y+1)*width]
+		for x := range dst {
+			dst[x] = float64(src[x])
+		}
+	}
+	return p
+}
+
+func plane16(pix []uint16, stride, width, height int) plane {
+	p := plane{width: width, height: height, pix: make([]float64, width*height)}
+	for y := 0; y < height; y += 1 {
+		src := pix[y*stride : y*stride+width]
+		dst := p.pix[y*width : (y+1)*width]
+		for x := range dst {
+			dst[x] = float64(src[x])
+		}
+	}
+	return p
+}
+
+// chromaSize returns the size of chroma planes, same as image.YCbCr
+func chromaSize(r image.Rectangle, ratio image.YCbCrSubsampleRatio) (int, int) {
+	dx, dy := subsampleFactor(ratio)
+	w := (r.Max.X+dx-1)/dx - r.Min.X/dx
+	h := (r.Max.Y+dy-1)/dy - r.Min.Y/dy
+	return w, h
+}
+
+func subsampleFactor(ratio image.YCbCrSubsampleRatio) (int, int) {
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		return 2, 1
+	case image.YCbCrSubsampleRatio420:
+		return 2, 2
+	case image.YCbCrSubsampleRatio440:
+		return 1, 2
+	case image.YCbCrSubsampleRatio411:
+		return 4, 1
+	case image.YCbCrSubsampleRatio410:
+		return 4, 2
+	}
+	return 1, 1
+}
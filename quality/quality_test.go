@@ -0,0 +1,201 @@
+package quality
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func testYCbCr(w, h int, offset int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for y := 0; y < h; y += 1 {
+		for x := 0; x < w; x += 1 {
+			img.Y[img.YOffset(x, y)] = uint8(40 + ((x*7 + y*13) % 150) + offset)
+		}
+	}
+	for i := range img.Cb {
+		img.Cb[i] = uint8(64 + (i % 128))
+		img.Cr[i] = uint8(192 - (i % 128))
+	}
+	return img
+}
+
+func testYCbCr16(w, h int, bitDepth int, offset int) *YCbCr16 {
+	cw, ch := (w+1)/2, (h+1)/2
+	img := &YCbCr16{
+		Y:              make([]uint16, w*h),
+		Cb:             make([]uint16, cw*ch),
+		Cr:             make([]uint16, cw*ch),
+		YStride:        w,
+		CStride:        cw,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, w, h),
+		BitDepth:       bitDepth,
+	}
+	for y := 0; y < h; y += 1 {
+		for x := 0; x < w; x += 1 {
+			img.Y[img.YOffset(x, y)] = uint16(160 + ((x*7 + y*13) % 600) + offset)
+		}
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 512
+		img.Cr[i] = 512
+	}
+	return img
+}
+
+func TestCompareIdentical(t *testing.T) {
+	ref := testYCbCr(256, 256, 0)
+	r, err := Compare(ref, testYCbCr(256, 256, 0))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(r.Planes) != 3 {
+		t.Fatalf("expect 3 planes actual %d", len(r.Planes))
+	}
+	for i, p := range r.Planes {
+		if p.MSE != 0 || p.PSNR != MaxPSNR {
+			t.Errorf("plane[%d] expect mse=0 psnr=%f actual mse=%f psnr=%f", i, MaxPSNR, p.MSE, p.PSNR)
+		}
+		if math.Abs(p.SSIM-1.0) > 1e-9 || math.Abs(p.MSSSIM-1.0) > 1e-9 {
+			t.Errorf("plane[%d] expect ssim=1 ms-ssim=1 actual ssim=%f ms-ssim=%f", i, p.SSIM, p.MSSSIM)
+		}
+	}
+	if r.PSNR() != MaxPSNR {
+		t.Errorf("expect weighted psnr %f actual %f", MaxPSNR, r.PSNR())
+	}
+}
+
+func TestCompareOffset(t *testing.T) {
+	ref := testYCbCr(256, 256, 0)
+	r, err := Compare(ref, testYCbCr(256, 256, 10))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	// every luma sample differs by 10
+	expectPSNR := 10.0 * math.Log10((255.0*255.0)/100.0)
+	y := r.Y()
+	if y.MSE != 100 {
+		t.Errorf("expect mse 100 actual %f", y.MSE)
+	}
+	if math.Abs(y.PSNR-expectPSNR) > 1e-9 {
+		t.Errorf("expect psnr %f actual %f", expectPSNR, y.PSNR)
+	}
+	// structure is kept, only luminance term decreases
+	if y.SSIM < 0.95 || 1.0 <= y.SSIM {
+		t.Errorf("expect ssim in [0.95, 1) actual %f", y.SSIM)
+	}
+	if y.MSSSIM < 0.95 || 1.0 <= y.MSSSIM {
+		t.Errorf("expect ms-ssim in [0.95, 1) actual %f", y.MSSSIM)
+	}
+	for i := 1; i < 3; i += 1 {
+		if r.Planes[i].PSNR != MaxPSNR {
+			t.Errorf("plane[%d] expect chroma unchanged actual psnr %f", i, r.Planes[i].PSNR)
+		}
+	}
+
+	worse, err := Compare(ref, testYCbCr(256, 256, 40))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if y.PSNR <= worse.Y().PSNR || y.SSIM <= worse.Y().SSIM || y.MSSSIM <= worse.Y().MSSSIM {
+		t.Errorf("expect larger offset decreases metrics: %+v %+v", y, worse.Y())
+	}
+}
+
+func TestCompare16(t *testing.T) {
+	ref := testYCbCr16(128, 128, 10, 0)
+	r, err := Compare(ref, testYCbCr16(128, 128, 10, 4))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expectPSNR := 10.0 * math.Log10((1023.0*1023.0)/16.0)
+	if math.Abs(r.Y().PSNR-expectPSNR) > 1e-9 {
+		t.Errorf("expect psnr %f for 10bit peak actual %f", expectPSNR, r.Y().PSNR)
+	}
+}
+
+func TestCompareError(t *testing.T) {
+	ref := testYCbCr(64, 64, 0)
+	if _, err := Compare(ref, testYCbCr(64, 32, 0)); err != ErrMismatch {
+		t.Errorf("expect ErrMismatch actual %v", err)
+	}
+	if _, err := Compare(ref, image.NewGray(image.Rect(0, 0, 64, 64))); err != ErrMismatch {
+		t.Errorf("expect ErrMismatch actual %v", err)
+	}
+	if _, err := Compare(ref, image.NewRGBA(image.Rect(0, 0, 64, 64))); err != ErrUnsupportedImage {
+		t.Errorf("expect ErrUnsupportedImage actual %v", err)
+	}
+	if _, err := Compare(ref, testYCbCr16(64, 64, 10, 0)); err != ErrBitDepthMismatch {
+		t.Errorf("expect ErrBitDepthMismatch actual %v", err)
+	}
+	if _, err := Compare(testYCbCr16(64, 64, 10, 0), testYCbCr16(64, 64, 12, 0)); err != ErrBitDepthMismatch {
+		t.Errorf("expect ErrBitDepthMismatch actual %v", err)
+	}
+	if _, err := Compare(image.NewGray(image.Rect(0, 0, 64, 64)), image.NewGray16(image.Rect(0, 0, 64, 64))); err != ErrBitDepthMismatch {
+		t.Errorf("expect ErrBitDepthMismatch actual %v", err)
+	}
+}
+
+// naiveSSIM evaluates the 2D Gaussian window directly at every position
+func naiveSSIM(a, b plane, peak float64) float64 {
+	c1, c2 := ssimConstants(peak)
+	sum, count := 0.0, 0
+	for y := 0; y+ssimWindow <= a.height; y += 1 {
+		for x := 0; x+ssimWindow <= a.width; x += 1 {
+			muA, muB, aa, bb, ab := 0.0, 0.0, 0.0, 0.0, 0.0
+			for j := 0; j < ssimWindow; j += 1 {
+				for i := 0; i < ssimWindow; i += 1 {
+					w := ssimKernel[j] * ssimKernel[i]
+					va, vb := a.pix[(y+j)*a.width+x+i], b.pix[(y+j)*b.width+x+i]
+					muA += w * va
+					muB += w * vb
+					aa += w * va * va
+					bb += w * vb * vb
+					ab += w * va * vb
+				}
+			}
+			l, cs := ssimStat(muA, muB, aa, bb, ab, c1, c2)
+			sum += l * cs
+			count += 1
+		}
+	}
+	return sum / float64(count)
+}
+
+func TestSSIMWindow(t *testing.T) {
+	t.Run("kernel", func(tt *testing.T) {
+		if len(ssimKernel) != 11 {
+			tt.Fatalf("expect 11 taps actual %d", len(ssimKernel))
+		}
+		sum := 0.0
+		for _, w := range ssimKernel {
+			sum += w
+		}
+		if math.Abs(sum-1.0) > 1e-12 {
+			tt.Errorf("expect normalized kernel actual sum %f", sum)
+		}
+		// exp(-25/4.5) relative to the center tap
+		if r := ssimKernel[0] / ssimKernel[5]; math.Abs(r-math.Exp(-25.0/4.5)) > 1e-12 {
+			tt.Errorf("expect sigma 1.5 actual edge/center %f", r)
+		}
+	})
+	t.Run("separable", func(tt *testing.T) {
+		ref := plane8(testYCbCr(37, 29, 0).Y, 37, 37, 29)
+		dist := plane8(testYCbCr(37, 29, 0).Y, 37, 37, 29)
+		for i := range dist.pix {
+			dist.pix[i] += float64((i * 31) % 17)
+		}
+		expect := naiveSSIM(ref, dist, 255.0)
+		if actual := ssim(ref, dist, 255.0); math.Abs(actual-expect) > 1e-9 {
+			tt.Errorf("expect %f actual %f", expect, actual)
+		}
+	})
+	t.Run("smaller than window", func(tt *testing.T) {
+		p := plane8(testYCbCr(8, 8, 0).Y, 8, 8, 8)
+		if v := ssim(p, p, 255.0); math.Abs(v-1.0) > 1e-9 {
+			tt.Errorf("expect 1 actual %f", v)
+		}
+	})
+}
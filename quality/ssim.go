@@ -0,0 +1,191 @@
+package quality
+
+import (
+	"math"
+)
+
+// SSIM follows Wang et al. 2004 (ssim_index.m): 11x11 circular-symmetric Gaussian window
+// with standard deviation 1.5, evaluated at every sample where the window fits inside the plane
+// (no padding), K1=0.01, K2=0.03. planes are not downsampled before SSIM.
+const (
+	ssimWindow int     = 11
+	ssimSigma  float64 = 1.5
+)
+
+// weights of MS-SSIM scales (Wang et al. 2003)
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// ssimKernel is the normalized 1D Gaussian, the 2D window is its outer product
+var ssimKernel = gaussianKernel(ssimWindow, ssimSigma)
+
+func gaussianKernel(size int, sigma float64) []float64 {
+	k := make([]float64, size)
+	center := float64(size-1) / 2.0
+	sum := 0.0
+	for i := range k {
+		d := float64(i) - center
+		k[i] = math.Exp(-(d * d) / (2.0 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	return k
+}
+
+// windowStats is the Gaussian weighted means of a, b, a^2, b^2 and ab per window
+type windowStats struct {
+	cols int
+	rows int
+	a    []float64
+	b    []float64
+	aa   []float64
+	bb   []float64
+	ab   []float64
+}
+
+func newWindowStats(cols, rows int) *windowStats {
+	return &windowStats{
+		cols: cols,
+		rows: rows,
+		a:    make([]float64, cols*rows),
+		b:    make([]float64, cols*rows),
+		aa:   make([]float64, cols*rows),
+		bb:   make([]float64, cols*rows),
+		ab:   make([]float64, cols*rows),
+	}
+}
+
+// filterWindows applies the separable Gaussian window to a and b, horizontal then vertical
+func filterWindows(a, b plane) *windowStats {
+	cols, rows := a.width-ssimWindow+1, a.height-ssimWindow+1
+
+	h := newWindowStats(cols, a.height)
+	for y := 0; y < a.height; y += 1 {
+		ra := a.pix[y*a.width : (y+1)*a.width]
+		rb := b.pix[y*b.width : (y+1)*b.width]
+		for x := 0; x < cols; x += 1 {
+			sa, sb, saa, sbb, sab := 0.0, 0.0, 0.0, 0.0, 0.0
+			for k, w := range ssimKernel {
+				va, vb := ra[x+k], rb[x+k]
+				sa += w * va
+				sb += w * vb
+				saa += w * va * va
+				sbb += w * vb * vb
+				sab += w * va * vb
+			}
+			i := (y * cols) + x
+			h.a[i], h.b[i], h.aa[i], h.bb[i], h.ab[i] = sa, sb, saa, sbb, sab
+		}
+	}
+
+	v := newWindowStats(cols, rows)
+	for y := 0; y < rows; y += 1 {
+		for x := 0; x < cols; x += 1 {
+			sa, sb, saa, sbb, sab := 0.0, 0.0, 0.0, 0.0, 0.0
+			for k, w := range ssimKernel {
+				j := ((y + k) * cols) + x
+				sa += w * h.a[j]
+				sb += w * h.b[j]
+				saa += w * h.aa[j]
+				sbb += w * h.bb[j]
+				sab += w * h.ab[j]
+			}
+			i := (y * cols) + x
+			v.a[i], v.b[i], v.aa[i], v.bb[i], v.ab[i] = sa, sb, saa, sbb, sab
+		}
+	}
+	return v
+}
+
+// ssimStat returns luminance and contrast-structure terms from weighted means
+func ssimStat(muA, muB, meanAA, meanBB, meanAB, c1, c2 float64) (float64, float64) {
+	varA := meanAA - (muA * muA)
+	varB := meanBB - (muB * muB)
+	cov := meanAB - (muA * muB)
+	l := ((2.0 * muA * muB) + c1) / ((muA * muA) + (muB * muB) + c1)
+	cs := ((2.0 * cov) + c2) / (varA + varB + c2)
+	return l, cs
+}
+
+func ssimConstants(peak float64) (float64, float64) {
+	c1 := (0.01 * peak) * (0.01 * peak)
+	c2 := (0.03 * peak) * (0.03 * peak)
+	return c1, c2
+}
+
+// ssimCS returns mean SSIM and mean contrast-structure of the Gaussian windows
+func ssimCS(a, b plane, peak float64) (float64, float64) {
+	c1, c2 := ssimConstants(peak)
+	if a.width < ssimWindow || a.height < ssimWindow {
+		// whole plane as a uniform window
+		sa, sb, saa, sbb, sab := 0.0, 0.0, 0.0, 0.0, 0.0
+		for i, va := range a.pix {
+			vb := b.pix[i]
+			sa += va
+			sb += vb
+			saa += va * va
+			sbb += vb * vb
+			sab += va * vb
+		}
+		n := float64(len(a.pix))
+		l, cs := ssimStat(sa/n, sb/n, saa/n, sbb/n, sab/n, c1, c2)
+		return l * cs, cs
+	}
+
+	s := filterWindows(a, b)
+	sumSSIM, sumCS := 0.0, 0.0
+	for i := range s.a {
+		l, cs := ssimStat(s.a[i], s.b[i], s.aa[i], s.bb[i], s.ab[i], c1, c2)
+		sumSSIM += l * cs
+		sumCS += cs
+	}
+	n := float64(len(s.a))
+	return sumSSIM / n, sumCS / n
+}
+
+func ssim(a, b plane, peak float64) float64 {
+	v, _ := ssimCS(a, b, peak)
+	return v
+}
+
+// msssim computes MS-SSIM with up to 5 scales (2x2 average between scales), weights are renormalized
+// when the plane is too small for all scales.
+func msssim(a, b plane, peak float64) float64 {
+	values := make([]float64, 0, len(msssimWeights))
+	for i := 0; i < len(msssimWeights); i += 1 {
+		last := i == len(msssimWeights)-1 || a.width/2 < ssimWindow || a.height/2 < ssimWindow
+		s, cs := ssimCS(a, b, peak)
+		if last {
+			values = append(values, s)
+			break
+		}
+		values = append(values, cs)
+		a, b = downsample(a), downsample(b)
+	}
+
+	weightSum := 0.0
+	for i := range values {
+		weightSum += msssimWeights[i]
+	}
+	result := 1.0
+	for i, v := range values {
+		result *= math.Pow(math.Max(v, 0), msssimWeights[i]/weightSum)
+	}
+	return result
+}
+
+// downsample averages 2x2 samples
+func downsample(p plane) plane {
+	w, h := p.width/2, p.height/2
+	d := plane{width: w, height: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y += 1 {
+		r0 := p.pix[(2*y)*p.width : (2*y)*p.width+(2*w)]
+		r1 := p.pix[(2*y+1)*p.width : (2*y+1)*p.width+(2*w)]
+		dst := d.pix[y*w : (y+1)*w]
+		for x := range dst {
+			dst[x] = (r0[2*x] + r0[2*x+1] + r1[2*x] + r1[2*x+1]) * 0.25
+		}
+	}
+	return d
+}
@@ -0,0 +1,88 @@
+package quality
+
+import (
+	"math"
+)
+
+// PlaneSummary is the aggregate metrics of a plane over frames
+type PlaneSummary struct {
+	// MeanPSNR is the average of per-frame PSNR
+	MeanPSNR float64
+	// GlobalPSNR is the PSNR of the average MSE
+	GlobalPSNR float64
+	MinPSNR    float64
+	MeanSSIM   float64
+	MinSSIM    float64
+	MeanMSSSIM float64
+}
+
+// Summary is the aggregate metrics of a sequence
+type Summary struct {
+	Frames int
+	Planes []PlaneSummary
+}
+
+// Stats accumulates per-frame Result of a sequence
+type Stats struct {
+	peak   float64
+	frames int
+	sumMSE []float64
+	sum    []PlaneSummary
+	min    []PlaneSummary
+}
+
+// NewStats creates Stats for bitDepth samples (8 for *image.YCbCr)
+func NewStats(bitDepth int) *Stats {
+	return &Stats{
+		peak: float64(int(1)<<bitDepth - 1),
+	}
+}
+
+func (s *Stats) Add(r Result) {
+	if s.frames == 0 {
+		s.sumMSE = make([]float64, len(r.Planes))
+		s.sum = make([]PlaneSummary, len(r.Planes))
+		s.min = make([]PlaneSummary, len(r.Planes))
+		for i := range s.min {
+			s.min[i] = PlaneSummary{MinPSNR: math.Inf(1), MinSSIM: math.Inf(1)}
+		}
+	}
+	s.frames += 1
+	for i, p := range r.Planes {
+		if len(s.sum) <= i {
+			break
+		}
+		s.sumMSE[i] += p.MSE
+		s.sum[i].MeanPSNR += p.PSNR
+		s.sum[i].MeanSSIM += p.SSIM
+		s.sum[i].MeanMSSSIM += p.MSSSIM
+		s.min[i].MinPSNR = math.Min(s.min[i].MinPSNR, p.PSNR)
+		s.min[i].MinSSIM = math.Min(s.min[i].MinSSIM, p.SSIM)
+	}
+}
+
+func (s *Stats) Frames() int {
+	return s.frames
+}
+
+func (s *Stats) Summary() Summary {
+	summary := Summary{
+		Frames: s.frames,
+		Planes: make([]PlaneSummary, len(s.sum)),
+	}
+	if s.frames == 0 {
+		return summary
+	}
+	n := float64(s.frames)
+	for i := range s.sum {
+		summary.Planes[i] = PlaneSummary{
+			MeanPSNR:   s.sum[i].MeanPSNR / n,
+			GlobalPSNR: psnr(s.sumMSE[i]/n, s.peak),
+			MinPSNR:    s.min[i].MinPSNR,
+			MeanSSIM:   s.sum[i].MeanSSIM / n,
+			MinSSIM:    s.min[i].MinSSIM,
+			MeanMSSSIM: s.sum[i].MeanMSSSIM / n,
+		}
+	}
+	return summary
+}
@@ -0,0 +1,208 @@
+// Package roundtrip is the integration suite of the binding against libxvc:
+// generated content is encoded, decoded and verified (dimensions, NAL type sequence,
+// user data and PTS propagation, PSNR threshold).
+//
+// run the suite with the race detector by go test or cmd/xvcroundtrip:
+//
+//	go test -race ./roundtrip
+//	go run -race ./cmd/xvcroundtrip
+package roundtrip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/octu0/go-xvc"
+	"github.com/octu0/go-xvc/bench"
+)
+
+var (
+	ErrSkipped = errors.New("roundtrip: skipped, libxvc not available")
+)
+
+// Format is chroma format and bit depth of the input
+type Format struct {
+	ChromaFormat xvc.ChromaFormat
+	BitDepth     int
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%s/%dbit", f.ChromaFormat, f.BitDepth)
+}
+
+// SupportedFormats returns the input formats of the suite.
+// pictures are decoded in the same chroma format with 8bit samples,
+// the input of 10bit is the 8bit content scaled, so that it is compared with the 8bit source.
+func SupportedFormats() []Format {
+	return []Format{
+		{ChromaFormat: xvc.ChromaFormat420, BitDepth: 8},
+		{ChromaFormat: xvc.ChromaFormat422, BitDepth: 8},
+		{ChromaFormat: xvc.ChromaFormat444, BitDepth: 8},
+		{ChromaFormat: xvc.ChromaFormatMonochrome, BitDepth: 8},
+		{ChromaFormat: xvc.ChromaFormat420, BitDepth: 10},
+	}
+}
+
+// Case is a round-trip configuration
+type Case struct {
+	Format   Format
+	Width    int
+	Height   int
+	Pattern  bench.Pattern
+	LowDelay bool
+	QP       int
+	Frames   int
+	// MinPSNR is the threshold of luma PSNR of every picture
+	MinPSNR float64
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("%s/%dx%d/%s/low_delay=%v/qp=%d", c.Format, c.Width, c.Height, c.Pattern, c.LowDelay, c.QP)
+}
+
+func minPSNR(p bench.Pattern) float64 {
+	switch p {
+	case bench.PatternNoise:
+		return 10.0 // incompressible content
+	}
+	return 30.0
+}
+
+// Cases returns the combinations of formats, sizes, patterns and delay modes
+func Cases() []Case {
+	sizes := [][2]int{{64, 48}, {176, 144}, {352, 288}}
+	patterns := []bench.Pattern{bench.PatternGradient, bench.PatternNoise, bench.PatternMovingSquare}
+	cases := make([]Case, 0, 32)
+	for _, f := range SupportedFormats() {
+		for _, s := range sizes {
+			for _, p := range patterns {
+				for _, lowDelay := range []bool{true, false} {
+					cases = append(cases, Case{
+						Format:   f,
+						Width:    s[0],
+						Height:   s[1],
+						Pattern:  p,
+						LowDelay: lowDelay,
+						QP:       27,
+						Frames:   20,
+						MinPSNR:  minPSNR(p),
+					})
+				}
+			}
+		}
+	}
+	return cases
+}
+
+// Result is the verified round-trip of a Case
+type Result struct {
+	Case     Case
+	NALs     int
+	Pictures int
+	Bytes    int
+	MinPSNR  float64
+}
+
+func subsampleRatio(f xvc.ChromaFormat) image.YCbCrSubsampleRatio {
+	switch f {
+	case xvc.ChromaFormat422:
+		return image.YCbCrSubsampleRatio422
+	case xvc.ChromaFormat444:
+		return image.YCbCrSubsampleRatio444
+	}
+	return image.YCbCrSubsampleRatio420
+}
+
+// reference returns the image expected to be decoded from img
+func reference(f Format, img *image.YCbCr) image.Image {
+	if f.ChromaFormat == xvc.ChromaFormatMonochrome {
+		return &image.Gray{Pix: img.Y, Stride: img.YStride, Rect: img.Rect}
+	}
+	return img
+}
+
+// inputPlanes returns planes and strides of img in the bit depth of f
+func inputPlanes(f Format, img *image.YCbCr) ([]byte, []byte, []byte, int, int) {
+	if f.BitDepth <= 8 {
+		return img.Y, img.Cb, img.Cr, img.YStride, img.CStride
+	}
+	shift := uint(f.BitDepth - 8)
+	return widen(img.Y, shift), widen(img.Cb, shift), widen(img.Cr, shift), img.YStride * 2, img.CStride * 2
+}
+
+// widen converts 8bit samples to 16bit little endian samples scaled by shift
+func widen(plane []byte, shift uint) []byte {
+	out := make([]byte, len(plane)*2)
+	for i, v := range plane {
+		w := uint16(v) << shift
+		out[2*i] = byte(w)
+		out[2*i+1] = byte(w >> 8)
+	}
+	return out
+}
+
+// Run encodes generated content of c, decodes and verifies it.
+// returns ErrSkipped if libxvc is not available.
+func Run(c Case) (Result, error) {
+	if xvc.Available() != true {
+		return Result{}, ErrSkipped
+	}
+
+	encoder, err := xvc.CreateEncoder(
+		xvc.EncoderParameterWidth(c.Width),
+		xvc.EncoderParameterHeight(c.Height),
+		xvc.EncoderParameterFramerate(30.0),
+		xvc.EncoderParameterQP(c.QP),
+		xvc.EncoderParameterLowDelay(c.LowDelay),
+		xvc.EncoderParameterChromaFormat(c.Format.ChromaFormat),
+		xvc.EncoderParameterInputBitDepth(c.Format.BitDepth),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	defer xvc.DestroyEncoder(encoder)
+
+	decoder, err := xvc.CreateDecoder(
+		xvc.DecoderParameterChromaFormat(c.Format.ChromaFormat),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+	defer xvc.DestroyDecoder(decoder)
+
+	v := newVerifier(c)
+	src := bench.NewSource(c.Width, c.Height, subsampleRatio(c.Format.ChromaFormat), c.Pattern)
+	for i := 0; i < c.Frames; i += 1 {
+		img := src.Frame(i)
+		v.sources[userData(i)] = reference(c.Format, img)
+
+		y, cb, cr, strideY, strideC := inputPlanes(c.Format, img)
+		nals, err := encoder.EncodeWithOptions(y, cb, cr, strideY, strideC, strideC, userData(i), xvc.EncodeOptions{
+			PTS: int64(i) * 33,
+		})
+		if err != nil {
+			return Result{}, err
+		}
+		if err := v.decode(decoder, nals); err != nil {
+			return Result{}, err
+		}
+	}
+	if nals, ok := encoder.Flush(); ok {
+		if err := v.decode(decoder, nals); err != nil {
+			return Result{}, err
+		}
+	}
+	if decoder.Flush() != true {
+		return Result{}, fmt.Errorf("%s: decoder flush failed", c)
+	}
+	if err := v.receive(decoder); err != nil {
+		return Result{}, err
+	}
+	return v.result()
+}
+
+// userData is distinct from frame number, to detect frame numbers leaking as user data
+func userData(frame int) int64 {
+	return int64(0x7e570000 + frame)
+}
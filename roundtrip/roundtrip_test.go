@@ -0,0 +1,36 @@
+package roundtrip
+
+import (
+	"testing"
+
+	"github.com/octu0/go-xvc"
+)
+
+// TestRoundtrip skips when libxvc can not be loaded (-tags xvc_dlopen),
+// the default build requires libxvc to compile.
+func TestRoundtrip(t *testing.T) {
+	if xvc.Available() != true {
+		t.Skip(xvc.ErrNotAvailable.Error())
+	}
+
+	for _, c := range Cases() {
+		c := c
+		t.Run(c.String(), func(tt *testing.T) {
+			tt.Parallel()
+
+			r, err := Run(c)
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			tt.Logf("nals=%d pictures=%d bytes=%d min_psnr=%.2f", r.NALs, r.Pictures, r.Bytes, r.MinPSNR)
+		})
+	}
+}
+
+func TestWiden(t *testing.T) {
+	out := widen([]byte{0x00, 0x80, 0xff}, 2)
+	expect := []byte{0x00, 0x00, 0x00, 0x02, 0xfc, 0x03}
+	if string(out) != string(expect) {
+		t.Errorf("expect %x actual %x", expect, out)
+	}
+}
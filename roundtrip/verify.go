@@ -0,0 +1,132 @@
+package roundtrip
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/octu0/go-xvc"
+	"github.com/octu0/go-xvc/quality"
+)
+
+type verifier struct {
+	c        Case
+	sources  map[int64]image.Image
+	types    []xvc.NALUnitType
+	received map[int64]bool
+	bytes    int
+	minPSNR  float64
+}
+
+func newVerifier(c Case) *verifier {
+	return &verifier{
+		c:        c,
+		sources:  make(map[int64]image.Image, c.Frames),
+		types:    make([]xvc.NALUnitType, 0, c.Frames*2),
+		received: make(map[int64]bool, c.Frames),
+		minPSNR:  quality.MaxPSNR,
+	}
+}
+
+func (v *verifier) decode(decoder *xvc.Decoder, nals []*xvc.NALUnit) error {
+	for _, nal := range nals {
+		v.types = append(v.types, nal.Type())
+		v.bytes += len(nal.Bytes())
+		err := decoder.DecodeNALUnit(nal)
+		nal.Close()
+		if err != nil {
+			return fmt.Errorf("%s: decode %s: %w", v.c, nal.Type(), err)
+		}
+	}
+	return v.receive(decoder)
+}
+
+func (v *verifier) receive(decoder *xvc.Decoder) error {
+	for {
+		pic, err := decoder.DecodedPicture()
+		if err != nil {
+			return nil
+		}
+		err = v.verifyPicture(pic)
+		pic.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (v *verifier) verifyPicture(pic *xvc.DecodedPicture) error {
+	if pic.Width() != v.c.Width || pic.Height() != v.c.Height {
+		return fmt.Errorf("%s: picture size %dx%d", v.c, pic.Width(), pic.Height())
+	}
+	src, ok := v.sources[pic.UserData()]
+	if ok != true {
+		return fmt.Errorf("%s: unknown user data %x", v.c, pic.UserData())
+	}
+	if v.received[pic.UserData()] {
+		return fmt.Errorf("%s: duplicate picture of user data %x", v.c, pic.UserData())
+	}
+	v.received[pic.UserData()] = true
+
+	frame := pic.UserData() - userData(0)
+	if pic.PTS() != frame*33 {
+		return fmt.Errorf("%s: frame %d pts %d", v.c, frame, pic.PTS())
+	}
+
+	img := pic.Image()
+	if img.Bounds() != src.Bounds() {
+		return fmt.Errorf("%s: image %v", v.c, img.Bounds())
+	}
+	// image type and subsample ratio mismatch is ErrMismatch
+	result, err := quality.Compare(src, img)
+	if err != nil {
+		return fmt.Errorf("%s: image %T: %w", v.c, img, err)
+	}
+	if result.Y().PSNR < v.c.MinPSNR {
+		return fmt.Errorf("%s: frame %d psnr %.2f < %.2f", v.c, frame, result.Y().PSNR, v.c.MinPSNR)
+	}
+	if result.Y().PSNR < v.minPSNR {
+		v.minPSNR = result.Y().PSNR
+	}
+	return nil
+}
+
+// verifyTypes checks the stream starts with SegmentHeader and IntraAccessPicture,
+// and contains a picture NAL per frame
+func (v *verifier) verifyTypes() error {
+	if len(v.types) < 2 || v.types[0] != xvc.SegmentHeader || v.types[1] != xvc.IntraAccessPicture {
+		return fmt.Errorf("%s: nal types %v", v.c, v.types)
+	}
+	pictures := 0
+	for _, t := range v.types {
+		if t.IsPicture() {
+			pictures += 1
+			continue
+		}
+		switch t {
+		case xvc.SegmentHeader, xvc.Sei, xvc.AccessUnitDelimiter:
+			// non-picture NALs
+		default:
+			return fmt.Errorf("%s: unexpected nal type %s", v.c, t)
+		}
+	}
+	if pictures != v.c.Frames {
+		return fmt.Errorf("%s: %d picture nals for %d frames", v.c, pictures, v.c.Frames)
+	}
+	return nil
+}
+
+func (v *verifier) result() (Result, error) {
+	if err := v.verifyTypes(); err != nil {
+		return Result{}, err
+	}
+	if len(v.received) != v.c.Frames {
+		return Result{}, fmt.Errorf("%s: decoded %d pictures for %d frames", v.c, len(v.received), v.c.Frames)
+	}
+	return Result{
+		Case:     v.c,
+		NALs:     len(v.types),
+		Pictures: len(v.received),
+		Bytes:    v.bytes,
+		MinPSNR:  v.minPSNR,
+	}, nil
+}
@@ -0,0 +1,222 @@
+package rtp
+
+import (
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	DefaultReorderWindow int = 64
+	// DefaultInitialJitter is the number of packets buffered before the first output,
+	// so that the first sequence number is not taken from a reordered packet.
+	DefaultInitialJitter int = 8
+)
+
+type NAL struct {
+	Data      []byte // length prefixed NAL unit, ready for xvc.Decoder.Decode
+	Type      xvctype.NALUnitType
+	Timestamp uint32
+	// EndOfPicture is true on the last NAL unit of a packet with marker bit
+	EndOfPicture bool
+	// Discontinuity is true on the first NAL unit after packet loss,
+	// decoding should restart from the next SegmentHeader / IntraAccessPicture.
+	Discontinuity bool
+}
+
+// Depacketizer reorders packets by sequence number and reassembles NAL units.
+type Depacketizer struct {
+	window        int
+	jitter        int
+	buffer        map[uint16]*Packet
+	expected      uint16
+	started       bool
+	fragment      []byte
+	discontinuity bool
+	lost          uint64
+}
+
+// NewDepacketizer creates Depacketizer, window is the number of packets to wait for a missing packet.
+func NewDepacketizer(window int) *Depacketizer {
+	if window < 1 {
+		window = DefaultReorderWindow
+	}
+	jitter := DefaultInitialJitter
+	if window < jitter {
+		jitter = window
+	}
+	return &Depacketizer{
+		window:   window,
+		jitter:   jitter,
+		buffer:   make(map[uint16]*Packet, window),
+		fragment: nil,
+	}
+}
+
+// Lost returns the number of packets lost so far
+func (d *Depacketizer) Lost() uint64 {
+	return d.lost
+}
+
+// Push adds pkt and returns NAL units that became complete in sequence number order.
+// the first output waits for DefaultInitialJitter packets (or window if smaller) and starts from the lowest sequence number of them.
+// late or duplicated packets are discarded.
+func (d *Depacketizer) Push(pkt *Packet) ([]NAL, error) {
+	if d.started != true {
+		if len(d.buffer) == 0 || int16(pkt.SequenceNumber-d.expected) < 0 {
+			d.expected = pkt.SequenceNumber
+		}
+		d.buffer[pkt.SequenceNumber] = pkt
+		if len(d.buffer) < d.jitter {
+			return nil, nil
+		}
+		d.started = true
+	} else {
+		if int16(pkt.SequenceNumber-d.expected) < 0 {
+			return nil, nil // late
+		}
+		if _, ok := d.buffer[pkt.SequenceNumber]; ok {
+			return nil, nil // duplicated
+		}
+		d.buffer[pkt.SequenceNumber] = pkt
+	}
+
+	nals := make([]NAL, 0, 4)
+	var lastErr error
+	for {
+		for {
+			p, ok := d.buffer[d.expected]
+			if ok != true {
+				break
+			}
+			delete(d.buffer, d.expected)
+			d.expected += 1
+
+			n, err := d.depacketize(p)
+			if err != nil {
+				d.markLoss()
+				lastErr = err
+				continue
+			}
+			nals = append(nals, n...)
+		}
+		if len(d.buffer) <= d.window {
+			break
+		}
+		d.skipToOldest()
+	}
+	return nals, lastErr
+}
+
+// Flush returns NAL units of all buffered packets, treating missing packets as lost.
+func (d *Depacketizer) Flush() ([]NAL, error) {
+	nals := make([]NAL, 0, len(d.buffer))
+	var lastErr error
+	if 0 < len(d.buffer) {
+		d.started = true
+	}
+	for 0 < len(d.buffer) {
+		d.skipToOldest()
+		for {
+			p, ok := d.buffer[d.expected]
+			if ok != true {
+				break
+			}
+			delete(d.buffer, d.expected)
+			d.expected += 1
+
+			n, err := d.depacketize(p)
+			if err != nil {
+				d.markLoss()
+				lastErr = err
+				continue
+			}
+			nals = append(nals, n...)
+		}
+	}
+	return nals, lastErr
+}
+
+func (d *Depacketizer) skipToOldest() {
+	oldest := d.expected
+	minDiff := -1
+	for seq := range d.buffer {
+		diff := int(uint16(seq - d.expected))
+		if minDiff < 0 || diff < minDiff {
+			minDiff = diff
+			oldest = seq
+		}
+	}
+	if 0 < minDiff {
+		d.lost += uint64(minDiff)
+		d.markLoss()
+	}
+	d.expected = oldest
+}
+
+func (d *Depacketizer) markLoss() {
+	d.fragment = nil
+	d.discontinuity = true
+}
+
+func (d *Depacketizer) newNAL(nal []byte, timestamp uint32) NAL {
+	n := NAL{
+		Data:          appendSizePrefix(nal),
+		Type:          nalUnitType(nal),
+		Timestamp:     timestamp,
+		EndOfPicture:  false,
+		Discontinuity: d.discontinuity,
+	}
+	d.discontinuity = false
+	return n
+}
+
+func (d *Depacketizer) depacketize(pkt *Packet) ([]NAL, error) {
+	payload := pkt.Payload
+	if len(payload) < 1 {
+		return nil, ErrInvalidPayload
+	}
+
+	nals := make([]NAL, 0, 1)
+	switch payloadType(payload[0]) {
+	case PayloadTypeAggregation:
+		data := payload[1:]
+		for 0 < len(data) {
+			if len(data) < apSizeField {
+				return nil, ErrInvalidPayload
+			}
+			size := (int(data[0]) << 8) | int(data[1])
+			data = data[apSizeField:]
+			if size < 1 || len(data) < size {
+				return nil, ErrInvalidPayload
+			}
+			nals = append(nals, d.newNAL(data[:size], pkt.Timestamp))
+			data = data[size:]
+		}
+	case PayloadTypeFragmentation:
+		if len(payload) < fuHeaderSize+1 {
+			return nil, ErrInvalidPayload
+		}
+		fuHeader := payload[1]
+		if (fuHeader & fuStartBit) != 0 {
+			if d.fragment != nil {
+				d.markLoss() // previous fragment is incomplete
+			}
+			typ := fuHeader & nalTypeMask
+			d.fragment = append(make([]byte, 0, len(payload)*4), payloadHeader(payload[0], typ))
+		}
+		if d.fragment == nil {
+			return nil, nil // start fragment was lost
+		}
+		d.fragment = append(d.fragment, payload[fuHeaderSize:]...)
+		if (fuHeader & fuEndBit) != 0 {
+			nals = append(nals, d.newNAL(d.fragment, pkt.Timestamp))
+			d.fragment = nil
+		}
+	default:
+		nals = append(nals, d.newNAL(payload, pkt.Timestamp))
+	}
+
+	if pkt.Marker && 0 < len(nals) {
+		nals[len(nals)-1].EndOfPicture = true
+	}
+	return nals, nil
+}
@@ -0,0 +1,95 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	headerSize int   = 12
+	version    uint8 = 2
+)
+
+var (
+	ErrPacketTooShort   = errors.New("rtp: packet too short")
+	ErrInvalidVersion   = errors.New("rtp: invalid version")
+	ErrInvalidPadding   = errors.New("rtp: invalid padding")
+	ErrInvalidExtension = errors.New("rtp: invalid header extension")
+)
+
+type Header struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+}
+
+type Packet struct {
+	Header
+	Payload []byte
+}
+
+// Marshal returns RTP packet bytes without CSRC and header extension
+func (p *Packet) Marshal() []byte {
+	buf := make([]byte, headerSize+len(p.Payload))
+	buf[0] = version << 6
+	buf[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		buf[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(buf[2:4], p.SequenceNumber)
+	binary.BigEndian.PutUint32(buf[4:8], p.Timestamp)
+	binary.BigEndian.PutUint32(buf[8:12], p.SSRC)
+	copy(buf[headerSize:], p.Payload)
+	return buf
+}
+
+// Unmarshal parses RTP packet, CSRC and header extension are skipped.
+// Payload refers to data.
+func Unmarshal(data []byte) (*Packet, error) {
+	if len(data) < headerSize {
+		return nil, ErrPacketTooShort
+	}
+	if (data[0] >> 6) != version {
+		return nil, ErrInvalidVersion
+	}
+	hasPadding := (data[0] & 0x20) != 0
+	hasExtension := (data[0] & 0x10) != 0
+	csrcCount := int(data[0] & 0x0f)
+
+	offset := headerSize + (csrcCount * 4)
+	if len(data) < offset {
+		return nil, ErrPacketTooShort
+	}
+	if hasExtension {
+		if len(data) < offset+4 {
+			return nil, ErrInvalidExtension
+		}
+		extLen := int(binary.BigEndian.Uint16(data[offset+2:offset+4])) * 4
+		offset += 4 + extLen
+		if len(data) < offset {
+			return nil, ErrInvalidExtension
+		}
+	}
+
+	end := len(data)
+	if hasPadding {
+		padding := int(data[end-1])
+		if padding < 1 || end-offset < padding {
+			return nil, ErrInvalidPadding
+		}
+		end -= padding
+	}
+
+	return &Packet{
+		Header: Header{
+			Marker:         (data[1] & 0x80) != 0,
+			PayloadType:    data[1] & 0x7f,
+			SequenceNumber: binary.BigEndian.Uint16(data[2:4]),
+			Timestamp:      binary.BigEndian.Uint32(data[4:8]),
+			SSRC:           binary.BigEndian.Uint32(data[8:12]),
+		},
+		Payload: data[offset:end],
+	}, nil
+}
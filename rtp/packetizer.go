@@ -0,0 +1,150 @@
+package rtp
+
+import (
+	"errors"
+)
+
+const (
+	DefaultMTU int = 1200
+	// MaxMTU is limited by the 16 bits size field of aggregation packet
+	MaxMTU int = 65535
+)
+
+var (
+	ErrMTUTooSmall = errors.New("rtp: mtu too small")
+	ErrMTUTooLarge = errors.New("rtp: mtu too large")
+)
+
+type Packetizer struct {
+	mtu         int
+	payloadType uint8
+	ssrc        uint32
+	seq         uint16
+}
+
+// NewPacketizer creates Packetizer, mtu is the maximum size of RTP packet including RTP header.
+func NewPacketizer(mtu int, payloadType uint8, ssrc uint32, initialSeq uint16) (*Packetizer, error) {
+	if mtu < headerSize+fuHeaderSize+1 {
+		return nil, ErrMTUTooSmall
+	}
+	if MaxMTU < mtu {
+		return nil, ErrMTUTooLarge
+	}
+	return &Packetizer{
+		mtu:         mtu,
+		payloadType: payloadType,
+		ssrc:        ssrc,
+		seq:         initialSeq,
+	}, nil
+}
+
+// Packetize packetizes length prefixed NAL units of one picture (e.g. SegmentHeader, Sei and a picture).
+// small NAL units are aggregated into one packet and NAL units larger than mtu are fragmented.
+// marker bit is set on the last packet when nals contains a picture NAL unit.
+func (p *Packetizer) Packetize(timestamp uint32, nals ...[]byte) ([]*Packet, error) {
+	maxPayload := p.mtu - headerSize
+
+	units := make([][]byte, 0, len(nals))
+	hasPicture := false
+	for _, n := range nals {
+		nal, err := stripSizePrefix(n)
+		if err != nil {
+			return nil, err
+		}
+		if nalUnitType(nal).IsPicture() {
+			hasPicture = true
+		}
+		units = append(units, nal)
+	}
+
+	payloads := make([][]byte, 0, len(units))
+	aggregate := make([][]byte, 0, len(units))
+	aggregateSize := 1 // payload header
+	flush := func() {
+		switch len(aggregate) {
+		case 0:
+			// nothing
+		case 1:
+			payloads = append(payloads, aggregate[0])
+		default:
+			payloads = append(payloads, aggregationPayload(aggregate, aggregateSize))
+		}
+		aggregate = aggregate[:0]
+		aggregateSize = 1
+	}
+
+	for _, nal := range units {
+		if maxPayload < len(nal) {
+			flush()
+			payloads = append(payloads, fragmentationPayloads(nal, maxPayload)...)
+			continue
+		}
+		if maxPayload < aggregateSize+apSizeField+len(nal) {
+			flush()
+		}
+		aggregate = append(aggregate, nal)
+		aggregateSize += apSizeField + len(nal)
+	}
+	flush()
+
+	packets := make([]*Packet, len(payloads))
+	for i, payload := range payloads {
+		packets[i] = &Packet{
+			Header: Header{
+				Marker:         hasPicture && i == len(payloads)-1,
+				PayloadType:    p.payloadType,
+				SequenceNumber: p.seq,
+				Timestamp:      timestamp,
+				SSRC:           p.ssrc,
+			},
+			Payload: payload,
+		}
+		p.seq += 1
+	}
+	return packets, nil
+}
+
+func aggregationPayload(nals [][]byte, size int) []byte {
+	// rfl / rfe bits of the aggregated NAL units are merged
+	var flags byte
+	for _, nal := range nals {
+		flags |= nal[0] & nalHeaderFlags
+	}
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, payloadHeader(flags, PayloadTypeAggregation))
+	for _, nal := range nals {
+		buf = append(buf, byte(len(nal)>>8), byte(len(nal)))
+		buf = append(buf, nal...)
+	}
+	return buf
+}
+
+func fragmentationPayloads(nal []byte, maxPayload int) [][]byte {
+	header := nal[0]
+	typ := uint8(nalUnitType(nal))
+	data := nal[1:]
+	fragmentSize := maxPayload - fuHeaderSize
+
+	payloads := make([][]byte, 0, (len(data)/fragmentSize)+1)
+	for offset := 0; offset < len(data); offset += fragmentSize {
+		end := offset + fragmentSize
+		if len(data) < end {
+			end = len(data)
+		}
+
+		fuHeader := typ
+		if offset == 0 {
+			fuHeader |= fuStartBit
+		}
+		if end == len(data) {
+			fuHeader |= fuEndBit
+		}
+
+		buf := make([]byte, 0, fuHeaderSize+(end-offset))
+		buf = append(buf, payloadHeader(header, PayloadTypeFragmentation), fuHeader)
+		buf = append(buf, data[offset:end]...)
+		payloads = append(payloads, buf)
+	}
+	return payloads
+}
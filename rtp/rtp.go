@@ -0,0 +1,70 @@
+// Package rtp implements RTP payload format of xvc NAL units.
+//
+// the format follows RFC 7798 (H.265) using xvc NAL unit header (rfl:2 type:5 rfe:1):
+//
+//	single NAL unit packet: NAL unit as is
+//	aggregation packet:     [payload header (type=24)] ([size:16][NAL unit])...
+//	fragmentation unit:     [payload header (type=25)] [S:1 E:1 R:1 type:5] [NAL unit payload fragment]
+//
+// NAL units given to Packetizer and returned from Depacketizer are length prefixed,
+// the same as xvc.NALUnit.Bytes() and the input of xvc.Decoder.Decode.
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+const (
+	PayloadTypeAggregation   uint8 = 24
+	PayloadTypeFragmentation uint8 = 25
+)
+
+const (
+	nalSizePrefix  int   = 4
+	apSizeField    int   = 2
+	fuHeaderSize   int   = 2
+	fuStartBit     uint8 = 0x80
+	fuEndBit       uint8 = 0x40
+	nalTypeMask    uint8 = 0x1f
+	nalHeaderFlags uint8 = 0xc1 // rfl + rfe
+)
+
+var (
+	ErrInvalidNAL     = errors.New("rtp: invalid nal unit")
+	ErrInvalidPayload = errors.New("rtp: invalid payload")
+)
+
+func payloadHeader(nalHeader byte, typ uint8) byte {
+	return (nalHeader & nalHeaderFlags) | ((typ & nalTypeMask) << 1)
+}
+
+func payloadType(header byte) uint8 {
+	return (header >> 1) & nalTypeMask
+}
+
+// stripSizePrefix returns NAL unit without the 4 bytes little endian size prefix
+func stripSizePrefix(nal []byte) ([]byte, error) {
+	if len(nal) < nalSizePrefix+1 {
+		return nil, ErrInvalidNAL
+	}
+	size := int(binary.LittleEndian.Uint32(nal[0:nalSizePrefix]))
+	if size < 1 || len(nal) < nalSizePrefix+size {
+		return nil, ErrInvalidNAL
+	}
+	return nal[nalSizePrefix : nalSizePrefix+size], nil
+}
+
+// appendSizePrefix returns length prefixed NAL unit
+func appendSizePrefix(nal []byte) []byte {
+	buf := make([]byte, nalSizePrefix+len(nal))
+	binary.LittleEndian.PutUint32(buf[0:nalSizePrefix], uint32(len(nal)))
+	copy(buf[nalSizePrefix:], nal)
+	return buf
+}
+
+func nalUnitType(nal []byte) xvctype.NALUnitType {
+	return xvctype.ParseNALUnitType(nal[0])
+}
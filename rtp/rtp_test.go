@@ -0,0 +1,221 @@
+package rtp
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/octu0/go-xvc/xvctype"
+)
+
+func testNAL(typ xvctype.NALUnitType, size int, seed int64) []byte {
+	nal := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(nal)
+	nal[0] = payloadHeader(0x01, uint8(typ))
+	return appendSizePrefix(nal)
+}
+
+func roundtrip(t *testing.T, d *Depacketizer, packets []*Packet) []NAL {
+	t.Helper()
+
+	nals := make([]NAL, 0, len(packets))
+	for _, pkt := range packets {
+		parsed, err := Unmarshal(pkt.Marshal())
+		if err != nil {
+			t.Fatalf("Unmarshal: %+v", err)
+		}
+		n, err := d.Push(parsed)
+		if err != nil {
+			t.Fatalf("Push: %+v", err)
+		}
+		nals = append(nals, n...)
+	}
+	n, err := d.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %+v", err)
+	}
+	return append(nals, n...)
+}
+
+func assertNALs(t *testing.T, expect [][]byte, actual []NAL) {
+	t.Helper()
+
+	if len(expect) != len(actual) {
+		t.Fatalf("expect %d nals actual %d", len(expect), len(actual))
+	}
+	for i := range expect {
+		if bytes.Equal(expect[i], actual[i].Data) != true {
+			t.Errorf("nal[%d] not same", i)
+		}
+	}
+}
+
+func TestPacketizerMTU(t *testing.T) {
+	if _, err := NewPacketizer(headerSize+fuHeaderSize, 96, 1, 0); err != ErrMTUTooSmall {
+		t.Errorf("expect ErrMTUTooSmall actual %v", err)
+	}
+	if _, err := NewPacketizer(MaxMTU+1, 96, 1, 0); err != ErrMTUTooLarge {
+		t.Errorf("expect ErrMTUTooLarge actual %v", err)
+	}
+	if _, err := NewPacketizer(MaxMTU, 96, 1, 0); err != nil {
+		t.Errorf("expect MaxMTU is valid: %+v", err)
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	t.Run("single", func(tt *testing.T) {
+		p, err := NewPacketizer(DefaultMTU, 96, 1, 0)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		pic := testNAL(xvctype.IntraAccessPicture, 1000, 1)
+		packets, err := p.Packetize(3000, pic)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if len(packets) != 1 {
+			tt.Fatalf("expect single nal unit packet actual %d packets", len(packets))
+		}
+		if packets[0].Marker != true {
+			tt.Errorf("expect marker on picture")
+		}
+
+		nals := roundtrip(tt, NewDepacketizer(DefaultReorderWindow), packets)
+		assertNALs(tt, [][]byte{pic}, nals)
+		if nals[0].Timestamp != 3000 {
+			tt.Errorf("expect timestamp 3000 actual %d", nals[0].Timestamp)
+		}
+		if nals[0].EndOfPicture != true {
+			tt.Errorf("expect end of picture")
+		}
+	})
+	t.Run("aggregation", func(tt *testing.T) {
+		p, err := NewPacketizer(DefaultMTU, 96, 1, 0)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		sh := testNAL(xvctype.SegmentHeader, 40, 1)
+		sei := testNAL(xvctype.Sei, 20, 2)
+		pic := testNAL(xvctype.IntraAccessPicture, 300, 3)
+		packets, err := p.Packetize(0, sh, sei, pic)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if len(packets) != 1 {
+			tt.Fatalf("expect 1 aggregation packet actual %d packets", len(packets))
+		}
+		if payloadType(packets[0].Payload[0]) != PayloadTypeAggregation {
+			tt.Errorf("expect aggregation packet")
+		}
+
+		nals := roundtrip(tt, NewDepacketizer(DefaultReorderWindow), packets)
+		assertNALs(tt, [][]byte{sh, sei, pic}, nals)
+		if nals[0].Type != xvctype.SegmentHeader {
+			tt.Errorf("expect segment header actual %s", nals[0].Type)
+		}
+		if nals[2].EndOfPicture != true {
+			tt.Errorf("expect end of picture on last nal")
+		}
+	})
+	t.Run("fragmentation", func(tt *testing.T) {
+		p, err := NewPacketizer(200, 96, 1, 0)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		sh := testNAL(xvctype.SegmentHeader, 40, 1)
+		pic := testNAL(xvctype.IntraAccessPicture, 1000, 2)
+		packets, err := p.Packetize(0, sh, pic)
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if len(packets) < 3 {
+			tt.Fatalf("expect fragmented packets actual %d packets", len(packets))
+		}
+		for i, pkt := range packets {
+			if 200 < len(pkt.Marshal()) {
+				tt.Errorf("packet[%d] exceeds mtu: %d", i, len(pkt.Marshal()))
+			}
+		}
+		if payloadType(packets[1].Payload[0]) != PayloadTypeFragmentation {
+			tt.Errorf("expect fragmentation unit")
+		}
+
+		nals := roundtrip(tt, NewDepacketizer(DefaultReorderWindow), packets)
+		assertNALs(tt, [][]byte{sh, pic}, nals)
+		if nals[1].Type != xvctype.IntraAccessPicture {
+			tt.Errorf("expect intra access picture actual %s", nals[1].Type)
+		}
+	})
+}
+
+func TestDepacketizerReorder(t *testing.T) {
+	p, err := NewPacketizer(200, 96, 1, 65530) // wraps around sequence number
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := make([][]byte, 0, 8)
+	packets := make([]*Packet, 0, 32)
+	for i := 0; i < 4; i += 1 {
+		pic := testNAL(xvctype.IntraPicture, 500, int64(i))
+		pkts, err := p.Packetize(uint32(i*3000), pic)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		expect = append(expect, pic)
+		packets = append(packets, pkts...)
+	}
+
+	// first packet arrives after the second one
+	packets[0], packets[1] = packets[1], packets[0]
+	packets[5], packets[7] = packets[7], packets[5]
+
+	d := NewDepacketizer(DefaultReorderWindow)
+	nals := roundtrip(t, d, packets)
+	assertNALs(t, expect, nals)
+	if d.Lost() != 0 {
+		t.Errorf("expect no loss actual %d", d.Lost())
+	}
+	for i, n := range nals {
+		if n.Discontinuity {
+			t.Errorf("nal[%d] unexpected discontinuity", i)
+		}
+	}
+}
+
+func TestDepacketizerLoss(t *testing.T) {
+	p, err := NewPacketizer(200, 96, 1, 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	pics := make([][]byte, 0, 3)
+	packets := make([][]*Packet, 0, 3)
+	for i := 0; i < 3; i += 1 {
+		pic := testNAL(xvctype.IntraPicture, 500, int64(i))
+		pkts, err := p.Packetize(uint32(i*3000), pic)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		pics = append(pics, pic)
+		packets = append(packets, pkts)
+	}
+
+	// middle fragment of the second picture is lost
+	received := make([]*Packet, 0, 16)
+	received = append(received, packets[0]...)
+	received = append(received, packets[1][0])
+	received = append(received, packets[1][2:]...)
+	received = append(received, packets[2]...)
+
+	d := NewDepacketizer(4)
+	nals := roundtrip(t, d, received)
+	assertNALs(t, [][]byte{pics[0], pics[2]}, nals)
+	if d.Lost() != 1 {
+		t.Errorf("expect 1 lost packet actual %d", d.Lost())
+	}
+	if nals[0].Discontinuity {
+		t.Errorf("expect first picture is continuous")
+	}
+	if nals[1].Discontinuity != true {
+		t.Errorf("expect discontinuity after loss")
+	}
+}
@@ -0,0 +1,241 @@
+package xvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	stillMagic      string = "XVCS"
+	stillVersion    byte   = 1
+	stillHeaderSize int    = 4 + 1 + 1 + 4 + 4
+
+	// stillFlagAlpha: color stream length (uint32 little endian), color stream and alpha stream follow the header
+	stillFlagAlpha byte = 1 << 0
+)
+
+func init() {
+	image.RegisterFormat("xvc", stillMagic+string(stillVersion), DecodeStill, DecodeConfig)
+}
+
+// stillHeader precedes the stream of a still picture
+//
+//	[0:4]   magic "XVCS"
+//	[4]     version
+//	[5]     flags (stillFlagAlpha)
+//	[6:10]  width  (little endian)
+//	[10:14] height (little endian)
+type stillHeader struct {
+	flags  byte
+	width  int
+	height int
+}
+
+func (h stillHeader) bytes() []byte {
+	buf := make([]byte, 0, stillHeaderSize)
+	buf = append(buf, stillMagic...)
+	buf = append(buf, stillVersion, h.flags)
+	buf = appendUint32(buf, uint32(h.width))
+	buf = appendUint32(buf, uint32(h.height))
+	return buf
+}
+
+func readStillHeader(r io.Reader) (stillHeader, error) {
+	buf := make([]byte, stillHeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return stillHeader{}, ErrInvalidStill
+	}
+	if string(buf[0:4]) != stillMagic || buf[4] != stillVersion {
+		return stillHeader{}, ErrInvalidStill
+	}
+	return stillHeader{
+		flags:  buf[5],
+		width:  int(binary.LittleEndian.Uint32(buf[6:10])),
+		height: int(binary.LittleEndian.Uint32(buf[10:14])),
+	}, nil
+}
+
+// EncodeStill writes img as a still picture, a stream of SegmentHeader and IntraAccessPicture
+// preceded by a header for image.Decode. funcs are applied to the Encoder (e.g. EncoderParameterQP),
+// img is encoded as 8bit 4:2:0, EncoderParameterChromaFormat and EncoderParameterInputBitDepth are overridden.
+// img with transparency is written with an auxiliary alpha stream (see AlphaEncoder).
+func EncodeStill(w io.Writer, img image.Image, funcs ...encoderParameterFunc) error {
+	b := img.Bounds()
+	header := stillHeader{
+		flags:  0,
+		width:  b.Dx(),
+		height: b.Dy(),
+	}
+	if hasAlpha(img) {
+		header.flags |= stillFlagAlpha
+		return encodeStillAlpha(w, header, img, funcs...)
+	}
+
+	buf := bytes.NewBuffer(header.bytes())
+	encoder := NewStreamEncoder(buf, funcs...)
+	if err := encoder.WriteFrame(img, 0); err != nil {
+		encoder.Close()
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func encodeStillAlpha(w io.Writer, header stillHeader, img image.Image, funcs ...encoderParameterFunc) error {
+	params := append([]encoderParameterFunc{}, funcs...)
+	params = append(params,
+		EncoderParameterWidth(header.width),
+		EncoderParameterHeight(header.height),
+	)
+	// 8bit 4:2:0 is forced by CreateAlphaEncoder
+	encoder, err := CreateAlphaEncoder(params...)
+	if err != nil {
+		return err
+	}
+	defer DestroyAlphaEncoder(encoder)
+
+	colorNALs, alphaNALs, err := encoder.Encode(img, 0, 0)
+	if err != nil {
+		return err
+	}
+	remainingColor, remainingAlpha := encoder.Flush()
+	colorNALs = append(colorNALs, remainingColor...)
+	alphaNALs = append(alphaNALs, remainingAlpha...)
+
+	colorStream := GroupAccessUnits(colorNALs)
+	alphaStream := GroupAccessUnits(alphaNALs)
+	defer func() {
+		for _, au := range append(colorStream, alphaStream...) {
+			au.Close()
+		}
+	}()
+
+	colorSize := 0
+	for _, au := range colorStream {
+		colorSize += len(au.Bytes())
+	}
+
+	buf := bytes.NewBuffer(header.bytes())
+	buf.Write(appendUint32(nil, uint32(colorSize)))
+	for _, au := range colorStream {
+		buf.Write(au.Bytes())
+	}
+	for _, au := range alphaStream {
+		buf.Write(au.Bytes())
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// DecodeStill decodes a still picture written by EncodeStill,
+// returns *image.YCbCr, or *image.NRGBA for the picture with transparency.
+func DecodeStill(r io.Reader) (image.Image, error) {
+	header, err := readStillHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if (header.flags & stillFlagAlpha) != 0 {
+		return decodeStillAlpha(r, header)
+	}
+
+	decoder, err := NewStreamDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+
+	pic, err := decoder.NextFrame()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidStill
+		}
+		return nil, err
+	}
+	defer pic.Close()
+
+	if pic.Width() != header.width || pic.Height() != header.height {
+		return nil, ErrInvalidStill
+	}
+	ycc, ok := pic.Image().(*image.YCbCr)
+	if ok != true {
+		return nil, ErrInvalidStill
+	}
+	return cloneYCbCr(ycc), nil
+}
+
+func decodeStillAlpha(r io.Reader, header stillHeader) (image.Image, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return nil, ErrInvalidStill
+	}
+	colorStream := io.LimitReader(r, int64(binary.LittleEndian.Uint32(size)))
+
+	decoder, err := CreateAlphaDecoder()
+	if err != nil {
+		return nil, err
+	}
+	defer DestroyAlphaDecoder(decoder)
+
+	if err := decodeAllNALs(colorStream, decoder.DecodeColor); err != nil {
+		return nil, err
+	}
+	if err := decodeAllNALs(r, decoder.DecodeAlpha); err != nil {
+		return nil, err
+	}
+	if decoder.Flush() != true {
+		return nil, ErrFlushFailed
+	}
+
+	pic, err := decoder.DecodedPicture()
+	if err != nil {
+		if err == DecReturnCode(DecNoDecodedPic) {
+			return nil, ErrInvalidStill
+		}
+		return nil, err
+	}
+	if pic.Image.Rect.Dx() != header.width || pic.Image.Rect.Dy() != header.height {
+		return nil, ErrInvalidStill
+	}
+	return pic.Image, nil
+}
+
+func decodeAllNALs(r io.Reader, decode func([]byte) error) error {
+	reader := NewNALReader(r)
+	for {
+		nal, err := reader.ReadNAL()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		err = decode(nal.Bytes())
+		nal.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// DecodeConfig returns the size of a still picture without decoding.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	header, err := readStillHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.YCbCrModel
+	if (header.flags & stillFlagAlpha) != 0 {
+		model = color.NRGBAModel
+	}
+	return image.Config{
+		ColorModel: model,
+		Width:      header.width,
+		Height:     header.height,
+	}, nil
+}
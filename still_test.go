@@ -0,0 +1,63 @@
+package xvc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStillHeader(t *testing.T) {
+	t.Run("DecodeConfig", func(tt *testing.T) {
+		tests := []struct {
+			header stillHeader
+			model  color.Model
+		}{
+			{stillHeader{flags: 0, width: 640, height: 480}, color.YCbCrModel},
+			{stillHeader{flags: stillFlagAlpha, width: 33, height: 17}, color.NRGBAModel},
+		}
+		for _, tc := range tests {
+			data := append(tc.header.bytes(), 0x00, 0x01, 0x02) // stream is not read
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				tt.Fatalf("%+v", err)
+			}
+			if format != "xvc" {
+				tt.Errorf("expect registered format xvc actual %s", format)
+			}
+			if cfg.Width != tc.header.width || cfg.Height != tc.header.height {
+				tt.Errorf("expect %dx%d actual %dx%d", tc.header.width, tc.header.height, cfg.Width, cfg.Height)
+			}
+			if cfg.ColorModel != tc.model {
+				tt.Errorf("flags=%d: unexpected color model", tc.header.flags)
+			}
+		}
+	})
+	t.Run("invalid", func(tt *testing.T) {
+		valid := stillHeader{width: 16, height: 16}.bytes()
+		badMagic := append([]byte{}, valid...)
+		badMagic[0] = 'Y'
+		badVersion := append([]byte{}, valid...)
+		badVersion[4] = stillVersion + 1
+
+		tests := []struct {
+			name string
+			data []byte
+		}{
+			{"magic", badMagic},
+			{"version", badVersion},
+			{"truncated", valid[:stillHeaderSize-1]},
+		}
+		for _, tc := range tests {
+			if _, err := readStillHeader(bytes.NewReader(tc.data)); err != ErrInvalidStill {
+				tt.Errorf("%s: expect ErrInvalidStill actual %v", tc.name, err)
+			}
+			if _, err := DecodeConfig(bytes.NewReader(tc.data)); err != ErrInvalidStill {
+				tt.Errorf("%s: DecodeConfig expect ErrInvalidStill actual %v", tc.name, err)
+			}
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewReader(badVersion)); err != image.ErrFormat {
+			tt.Errorf("expect unknown version is not matched by the registered magic actual %v", err)
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package xvc
+
+import (
+	"image"
+	"io"
+)
+
+// StreamEncoder encodes frames and writes length prefixed NAL units to io.Writer.
+// NALUnits are released to the BufferPool after being written.
+type StreamEncoder struct {
+	w       io.Writer
+	funcs   []encoderParameterFunc
+	encoder *Encoder
+	bounds  image.Rectangle
+	frames  int64
+	closed  bool
+}
+
+// NewStreamEncoder creates StreamEncoder, Encoder is created by the first WriteFrame
+// with funcs and the size of the frame. frames are encoded as 8bit 4:2:0,
+// EncoderParameterChromaFormat and EncoderParameterInputBitDepth in funcs are overridden.
+func NewStreamEncoder(w io.Writer, funcs ...encoderParameterFunc) *StreamEncoder {
+	return &StreamEncoder{
+		w:       w,
+		funcs:   funcs,
+		encoder: nil,
+		frames:  0,
+		closed:  false,
+	}
+}
+
+// WriteFrame encodes img with pts in the Timebase of the Encoder (milliseconds by default).
+// img other than 4:2:0 *image.YCbCr is converted. when the size of img changes,
+// a new segment is started by Encoder.Reconfigure.
+func (s *StreamEncoder) WriteFrame(img image.Image, pts int64) error {
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	ycc := toYCbCr420(img)
+	width, height := ycc.Rect.Dx(), ycc.Rect.Dy()
+	if s.encoder == nil {
+		funcs := append([]encoderParameterFunc{}, s.funcs...)
+		funcs = append(funcs,
+			EncoderParameterWidth(width),
+			EncoderParameterHeight(height),
+			// planes of toYCbCr420
+			EncoderParameterChromaFormat(ChromaFormat420),
+			EncoderParameterInputBitDepth(8),
+		)
+		encoder, err := CreateEncoder(funcs...)
+		if err != nil {
+			return err
+		}
+		s.encoder = encoder
+		s.bounds = ycc.Rect
+	}
+	if s.bounds != ycc.Rect {
+		nals, err := s.encoder.Reconfigure(EncoderParameterWidth(width), EncoderParameterHeight(height))
+		if werr := s.writeNALs(nals); werr != nil {
+			return werr
+		}
+		if err != nil {
+			return err
+		}
+		s.bounds = ycc.Rect
+	}
+
+	nals, err := s.encoder.EncodeWithOptions(ycc.Y, ycc.Cb, ycc.Cr, ycc.YStride, ycc.CStride, ycc.CStride, s.frames, EncodeOptions{
+		PTS: pts,
+	})
+	if err != nil {
+		return err
+	}
+	s.frames += 1
+	return s.writeNALs(nals)
+}
+
+// writeNALs writes and closes all nals, even if Write fails
+func (s *StreamEncoder) writeNALs(nals []*NALUnit) error {
+	var err error
+	for _, nal := range nals {
+		if err == nil {
+			_, err = s.w.Write(nal.Bytes())
+		}
+		nal.Close()
+	}
+	return err
+}
+
+// Close flushes the Encoder and writes the remaining NALs, w is not closed.
+// ErrFlushFailed is returned if the Encoder could not be flushed.
+func (s *StreamEncoder) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.encoder == nil {
+		return nil
+	}
+	defer DestroyEncoder(s.encoder)
+
+	nals, ok := s.encoder.Flush()
+	if ok != true {
+		return ErrFlushFailed
+	}
+	return s.writeNALs(nals)
+}
+
+// StreamDecoder reads length prefixed NAL units from io.Reader and yields decoded pictures.
+type StreamDecoder struct {
+	reader  *NALReader
+	decoder *Decoder
+	flushed bool
+	closed  bool
+}
+
+func NewStreamDecoder(r io.Reader, funcs ...decoderParameterFunc) (*StreamDecoder, error) {
+	decoder, err := CreateDecoder(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamDecoder{
+		reader:  NewNALReader(r),
+		decoder: decoder,
+		flushed: false,
+		closed:  false,
+	}, nil
+}
+
+// NextFrame returns the next picture in output order, io.EOF at the end of stream.
+// the decoder is flushed at the end of r, the returned DecodedPicture must be closed.
+func (s *StreamDecoder) NextFrame() (*DecodedPicture, error) {
+	if s.closed {
+		return nil, ErrStreamClosed
+	}
+	for {
+		pic, err := s.decoder.DecodedPicture()
+		if err == nil {
+			return pic, nil
+		}
+		if err != DecReturnCode(DecNoDecodedPic) {
+			return nil, err
+		}
+		if s.flushed {
+			return nil, io.EOF
+		}
+
+		nal, err := s.reader.ReadNAL()
+		if err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			if s.decoder.Flush() != true {
+				return nil, ErrFlushFailed
+			}
+			s.flushed = true
+			continue
+		}
+		err = s.decoder.Decode(nal.Bytes())
+		nal.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close destroys the Decoder, r is not closed.
+func (s *StreamDecoder) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return DestroyDecoder(s.decoder)
+}
@@ -0,0 +1,74 @@
+package xvc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func skipIfNotAvailable(t *testing.T) {
+	t.Helper()
+
+	if Available() != true {
+		t.Skip(ErrNotAvailable.Error())
+	}
+}
+
+func TestStreamDecoderClose(t *testing.T) {
+	s := &StreamDecoder{closed: true}
+	if err := s.Close(); err != nil {
+		t.Errorf("expect closed twice without error actual %v", err)
+	}
+	if _, err := s.NextFrame(); err != ErrStreamClosed {
+		t.Errorf("expect ErrStreamClosed actual %v", err)
+	}
+}
+
+func TestStreamDecoderNextFrame(t *testing.T) {
+	skipIfNotAvailable(t)
+
+	t.Run("empty", func(tt *testing.T) {
+		s, err := NewStreamDecoder(bytes.NewReader(nil))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		defer s.Close()
+
+		// flushed at the end of stream, io.EOF afterwards
+		for i := 0; i < 2; i += 1 {
+			if _, err := s.NextFrame(); err != io.EOF {
+				tt.Errorf("expect io.EOF actual %v", err)
+			}
+		}
+		if s.flushed != true {
+			tt.Errorf("expect flushed")
+		}
+	})
+	t.Run("truncated", func(tt *testing.T) {
+		nal := testNAL(SegmentHeader, 16, 0)
+		s, err := NewStreamDecoder(bytes.NewReader(nal[:len(nal)-1]))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		defer s.Close()
+
+		if _, err := s.NextFrame(); err != io.ErrUnexpectedEOF {
+			tt.Errorf("expect io.ErrUnexpectedEOF actual %v", err)
+		}
+		if s.flushed {
+			tt.Errorf("expect not flushed on error")
+		}
+	})
+	t.Run("close twice", func(tt *testing.T) {
+		s, err := NewStreamDecoder(bytes.NewReader(nil))
+		if err != nil {
+			tt.Fatalf("%+v", err)
+		}
+		if err := s.Close(); err != nil {
+			tt.Errorf("%+v", err)
+		}
+		if err := s.Close(); err != nil {
+			tt.Errorf("expect no error actual %v", err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+#!/bin/sh
+#
+# builds libxvcenc / libxvcdec as static libraries for `-tags xvc_static`.
+# headers are installed to ./include and archives to ./lib of this module.
+#
+# the source is taken from third_party/xvc when vendored,
+# otherwise XVC_REPO is cloned at the commit written in third_party/XVC_REF (network access is required).
+# the module directory has to be writable, run it in a clone of this module, not in the module cache.
+#
+set -eu
+
+ROOT_DIR=$(cd "$(dirname "$0")/.." && pwd)
+SRC_DIR="${ROOT_DIR}/third_party/xvc"
+BUILD_DIR="${ROOT_DIR}/third_party/build"
+
+XVC_REPO="${XVC_REPO:-https://github.com/divideon/xvc.git}"
+XVC_REF="${XVC_REF:-$(grep -v '^#' "${ROOT_DIR}/third_party/XVC_REF" | head -n 1)}"
+
+# branches and tags can move, only a full commit SHA keeps the binding and the library in sync
+if ! printf '%s' "${XVC_REF}" | grep -Eq '^[0-9a-f]{40}$'; then
+  echo "third_party/XVC_REF must be a full commit SHA of ${XVC_REPO}: '${XVC_REF}'" >&2
+  exit 1
+fi
+
+if [ ! -w "${ROOT_DIR}" ]; then
+  echo "${ROOT_DIR} is not writable, run go generate in a clone of this module" >&2
+  exit 1
+fi
+
+if [ ! -f "${SRC_DIR}/CMakeLists.txt" ]; then
+  git clone "${XVC_REPO}" "${SRC_DIR}"
+  git -C "${SRC_DIR}" checkout --detach "${XVC_REF}"
+fi
+
+if [ -d "${SRC_DIR}/.git" ]; then
+  HEAD_REF=$(git -C "${SRC_DIR}" rev-parse HEAD)
+  if [ "${HEAD_REF}" != "${XVC_REF}" ]; then
+    echo "third_party/xvc is at ${HEAD_REF}, expected ${XVC_REF}" >&2
+    exit 1
+  fi
+fi
+
+mkdir -p "${BUILD_DIR}" "${ROOT_DIR}/include" "${ROOT_DIR}/lib"
+cd "${BUILD_DIR}"
+cmake "${SRC_DIR}" \
+  -DCMAKE_BUILD_TYPE=Release \
+  -DBUILD_SHARED_LIBS=OFF \
+  -DCMAKE_POSITION_INDEPENDENT_CODE=ON \
+  -DBUILD_TESTS=OFF \
+  -DCMAKE_INSTALL_PREFIX="${BUILD_DIR}/dist"
+cmake --build . --target xvcenc xvcdec
+
+find . -name 'libxvcenc.a' -exec cp {} "${ROOT_DIR}/lib/" \;
+find . -name 'libxvcdec.a' -exec cp {} "${ROOT_DIR}/lib/" \;
+cp "${SRC_DIR}/src/xvc_enc_lib/xvcenc.h" "${ROOT_DIR}/include/"
+cp "${SRC_DIR}/src/xvc_dec_lib/xvcdec.h" "${ROOT_DIR}/include/"
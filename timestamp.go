@@ -0,0 +1,157 @@
+package xvc
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+const (
+	defaultSubGOPLength int = 16 // libxvc default sub_gop_length
+)
+
+// Timebase is the unit of PTS/DTS, one tick is Num/Den seconds.
+type Timebase struct {
+	Num int
+	Den int
+}
+
+var (
+	TimebaseMillisecond = Timebase{1, 1000}
+	Timebase90kHz       = Timebase{1, 90000}
+)
+
+// Valid reports whether Num and Den are positive
+func (tb Timebase) Valid() bool {
+	return 0 < tb.Num && 0 < tb.Den
+}
+
+// Duration converts ticks to time.Duration, 0 if tb is not Valid.
+func (tb Timebase) Duration(ticks int64) time.Duration {
+	if tb.Valid() != true {
+		return 0
+	}
+	// split into quotient and remainder not to overflow int64 with ticks * time.Second
+	n := ticks * int64(tb.Num)
+	den := int64(tb.Den)
+	return time.Duration(n/den)*time.Second + time.Duration((n%den)*int64(time.Second)/den)
+}
+
+// Ticks converts d to ticks, 0 if tb is not Valid.
+func (tb Timebase) Ticks(d time.Duration) int64 {
+	if tb.Valid() != true {
+		return 0
+	}
+	unit := int64(time.Second) * int64(tb.Num)
+	den := int64(tb.Den)
+	return (int64(d)/unit)*den + (int64(d)%unit)*den/unit
+}
+
+// ticksPerFrame returns frame duration in ticks
+func (tb Timebase) ticksPerFrame(framerate float32) int64 {
+	if framerate <= 0 || tb.Valid() != true {
+		return 1
+	}
+	ticks := int64(math.Round(float64(tb.Den) / (float64(tb.Num) * float64(framerate))))
+	if ticks < 1 {
+		return 1
+	}
+	return ticks
+}
+
+// frameInfo is the per-picture data associated with libxvc user_data
+type frameInfo struct {
+	userData int64
+	pts      int64
+}
+
+// frameTable maps the sequence number passed to libxvc as user_data
+// to the user data and PTS given by the caller.
+type frameTable struct {
+	seq    int64
+	frames map[int64]frameInfo
+}
+
+func newFrameTable() *frameTable {
+	return &frameTable{
+		seq:    0,
+		frames: make(map[int64]frameInfo),
+	}
+}
+
+func (t *frameTable) put(info frameInfo) int64 {
+	t.seq += 1
+	t.frames[t.seq] = info
+	return t.seq
+}
+
+func (t *frameTable) take(seq int64) (frameInfo, bool) {
+	info, ok := t.frames[seq]
+	if ok {
+		delete(t.frames, seq)
+	}
+	return info, ok
+}
+
+func (t *frameTable) reset() {
+	t.frames = make(map[int64]frameInfo)
+}
+
+// dtsGenerator generates DTS in decoding order.
+// DTS of n-th picture in decoding order is the n-th smallest PTS shifted by the reorder delay,
+// so that DTS is monotonic and never exceeds PTS.
+type dtsGenerator struct {
+	ptsQueue []int64
+	delay    int64
+}
+
+func newDTSGenerator(delay int64) *dtsGenerator {
+	return &dtsGenerator{
+		ptsQueue: make([]int64, 0, 64),
+		delay:    delay,
+	}
+}
+
+func (g *dtsGenerator) push(pts int64) {
+	i := sort.Search(len(g.ptsQueue), func(i int) bool {
+		return pts < g.ptsQueue[i]
+	})
+	g.ptsQueue = append(g.ptsQueue, 0)
+	copy(g.ptsQueue[i+1:], g.ptsQueue[i:])
+	g.ptsQueue[i] = pts
+}
+
+func (g *dtsGenerator) remove(pts int64) {
+	for i, v := range g.ptsQueue {
+		if v == pts {
+			g.ptsQueue = append(g.ptsQueue[:i], g.ptsQueue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *dtsGenerator) pop(pts int64) int64 {
+	if len(g.ptsQueue) < 1 {
+		return pts - g.delay
+	}
+	head := g.ptsQueue[0]
+	g.ptsQueue = g.ptsQueue[1:]
+	return head - g.delay
+}
+
+func (g *dtsGenerator) reset(delay int64) {
+	g.ptsQueue = g.ptsQueue[:0]
+	g.delay = delay
+}
+
+// reorderDepth returns the number of pictures by which decoding order precedes output order
+func reorderDepth(lowDelay int, subGOPLength int) int {
+	if lowDelay == 1 {
+		return 0
+	}
+	if subGOPLength < 1 {
+		subGOPLength = defaultSubGOPLength
+	}
+	return bits.Len(uint(subGOPLength - 1)) // ceil(log2(subGOPLength))
+}
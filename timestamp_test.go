@@ -0,0 +1,161 @@
+package xvc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimebase(t *testing.T) {
+	tests := []struct {
+		name     string
+		tb       Timebase
+		ticks    int64
+		duration time.Duration
+	}{
+		{"millisecond", TimebaseMillisecond, 1500, 1500 * time.Millisecond},
+		{"90kHz", Timebase90kHz, 90000, time.Second},
+		{"90kHz 30h", Timebase90kHz, 30 * 3600 * 90000, 30 * time.Hour},
+		{"90kHz negative", Timebase90kHz, -45000, -500 * time.Millisecond},
+		{"ntsc", Timebase{1001, 30000}, 30, 1001 * time.Millisecond},
+		{"invalid", Timebase{}, 100, 0},
+		{"negative den", Timebase{1, -1000}, 100, 0},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(tt *testing.T) {
+			if d := tc.tb.Duration(tc.ticks); d != tc.duration {
+				tt.Errorf("Duration: expect %s actual %s", tc.duration, d)
+			}
+			if tc.tb.Valid() != true {
+				if ticks := tc.tb.Ticks(tc.duration); ticks != 0 {
+					tt.Errorf("Ticks: expect 0 actual %d", ticks)
+				}
+				return
+			}
+			if ticks := tc.tb.Ticks(tc.duration); ticks != tc.ticks {
+				tt.Errorf("Ticks: expect %d actual %d", tc.ticks, ticks)
+			}
+		})
+	}
+}
+
+func TestTicksPerFrame(t *testing.T) {
+	tests := []struct {
+		tb        Timebase
+		framerate float32
+		expect    int64
+	}{
+		{TimebaseMillisecond, 30, 33},
+		{TimebaseMillisecond, 25, 40},
+		{Timebase90kHz, 30, 3000},
+		{Timebase90kHz, 29.97, 3003},
+		{TimebaseMillisecond, 0, 1},
+		{Timebase{}, 30, 1},
+	}
+	for _, tc := range tests {
+		if ticks := tc.tb.ticksPerFrame(tc.framerate); ticks != tc.expect {
+			t.Errorf("%v %v fps: expect %d actual %d", tc.tb, tc.framerate, tc.expect, ticks)
+		}
+	}
+}
+
+func TestReorderDepth(t *testing.T) {
+	tests := []struct {
+		lowDelay     int
+		subGOPLength int
+		expect       int
+	}{
+		{1, 16, 0},
+		{0, 0, 4}, // libxvc default 16
+		{0, 1, 0},
+		{0, 2, 1},
+		{0, 4, 2},
+		{0, 8, 3},
+		{0, 16, 4},
+		{0, 64, 6},
+	}
+	for _, tc := range tests {
+		if depth := reorderDepth(tc.lowDelay, tc.subGOPLength); depth != tc.expect {
+			t.Errorf("low_delay=%d sub_gop_length=%d: expect %d actual %d", tc.lowDelay, tc.subGOPLength, tc.expect, depth)
+		}
+	}
+}
+
+func TestDTSGenerator(t *testing.T) {
+	// sub_gop_length 4: pictures are pushed in input order and output in decoding order I0 P4 B2 b1 b3 P8 B6 b5 b7
+	t.Run("b-picture reorder", func(tt *testing.T) {
+		g := newDTSGenerator(int64(reorderDepth(0, 4)))
+		steps := []struct {
+			push   []int64
+			output []int64
+		}{
+			{[]int64{0}, []int64{0}},
+			{[]int64{1, 2, 3, 4}, []int64{4, 2, 1, 3}},
+			{[]int64{5, 6, 7, 8}, []int64{8, 6, 5, 7}},
+		}
+		prev := int64(-1 << 62)
+		for _, s := range steps {
+			for _, pts := range s.push {
+				g.push(pts)
+			}
+			for _, pts := range s.output {
+				dts := g.pop(pts)
+				if pts < dts {
+					tt.Errorf("pts=%d: expect dts <= pts actual dts=%d", pts, dts)
+				}
+				if dts <= prev {
+					tt.Errorf("pts=%d: expect monotonic dts actual %d after %d", pts, dts, prev)
+				}
+				prev = dts
+			}
+		}
+	})
+	t.Run("remove failed picture", func(tt *testing.T) {
+		g := newDTSGenerator(0)
+		g.push(0)
+		g.push(1)
+		g.remove(0)
+		if dts := g.pop(1); dts != 1 {
+			tt.Errorf("expect 1 actual %d", dts)
+		}
+	})
+	t.Run("empty queue", func(tt *testing.T) {
+		g := newDTSGenerator(2)
+		if dts := g.pop(10); dts != 8 {
+			tt.Errorf("expect pts - delay actual %d", dts)
+		}
+	})
+	t.Run("reset", func(tt *testing.T) {
+		g := newDTSGenerator(2)
+		g.push(0)
+		g.reset(0)
+		if dts := g.pop(5); dts != 5 {
+			tt.Errorf("expect queue cleared and delay 0 actual %d", dts)
+		}
+	})
+}
+
+func TestFrameTable(t *testing.T) {
+	table := newFrameTable()
+	a := table.put(frameInfo{userData: 100, pts: 0})
+	b := table.put(frameInfo{userData: 200, pts: 33})
+	if a == b {
+		t.Fatalf("expect unique seq actual %d %d", a, b)
+	}
+
+	info, ok := table.take(b)
+	if ok != true || info.userData != 200 || info.pts != 33 {
+		t.Errorf("expect userData=200 pts=33 actual %+v %v", info, ok)
+	}
+	if _, ok := table.take(b); ok {
+		t.Errorf("expect taken only once")
+	}
+
+	table.reset()
+	if _, ok := table.take(a); ok {
+		t.Errorf("expect cleared by reset")
+	}
+	if c := table.put(frameInfo{}); c == a || c == b {
+		t.Errorf("expect seq not reused after reset actual %d", c)
+	}
+}